@@ -1,14 +1,14 @@
 // Package bench_test implements benchmarks for the scapegoat tree
-// implementation with integer keys.
+// implementation with integer keys, comparing the package generated by mktree
+// with a direct instantiation of the generic tree.
 //
 // To run these tests you must first generate the package:
 //
-//    go generate ./bench
+//	go generate ./bench
 //
 // Then run the tests normally:
 //
-//    go test -bench=. ./bench
-//
+//	go test -bench=. ./bench
 package bench_test
 
 import (
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/creachadair/scapegoat/bench"
+	"github.com/creachadair/scapegoat/generic"
 )
 
 const benchSeed = 1471808909908695897
@@ -26,84 +27,169 @@ const benchSeed = 1471808909908695897
 // Trial values of β for load-testing tree operations.
 var balances = []int{0, 50, 100, 150, 200, 250, 300, 500, 800, 1000}
 
-func randomTree(b *testing.B, β int) (*bench.Tree, []bench.KV) {
+// A newFunc constructs a tree with the given balance and contents.
+type newFunc func(β int, kvs ...bench.KV) *bench.Tree
+
+// Tree implementations under test. The generated package orders keys with its
+// own keyLess function, while the generic instantiation uses cmp.Compare.
+var impls = []struct {
+	name string
+	new  newFunc
+}{
+	{"generated", bench.New},
+	{"generic", generic.New[int, int]},
+}
+
+// runBalances runs f as a sub-benchmark for each implementation and balance.
+func runBalances(b *testing.B, f func(b *testing.B, newTree newFunc, β int)) {
+	for _, impl := range impls {
+		for _, β := range balances {
+			b.Run(fmt.Sprintf("%s/β=%d", impl.name, β), func(b *testing.B) {
+				f(b, impl.new, β)
+			})
+		}
+	}
+}
+
+func randomTree(b *testing.B, newTree newFunc, β int) (*bench.Tree, []bench.KV) {
 	rng := rand.New(rand.NewSource(benchSeed))
 	values := make([]bench.KV, b.N)
 	for i := range values {
 		values[i].Key = rng.Intn(math.MaxInt32)
 	}
-	return bench.New(β, values...), values
+	return newTree(β, values...), values
 }
 
 func BenchmarkNew(b *testing.B) {
-	for _, β := range balances {
-		b.Run(fmt.Sprintf("β=%d", β), func(b *testing.B) {
-			randomTree(b, β)
-		})
-	}
+	runBalances(b, func(b *testing.B, newTree newFunc, β int) {
+		randomTree(b, newTree, β)
+	})
 }
 
 func BenchmarkInsertRandom(b *testing.B) {
-	for _, β := range balances {
-		b.Run(fmt.Sprintf("β=%d", β), func(b *testing.B) {
-			_, values := randomTree(b, β)
-			b.ResetTimer()
-			tree := bench.New(β)
-			for _, v := range values {
-				tree.Insert(v.Key, v.Value)
-			}
-		})
-	}
+	runBalances(b, func(b *testing.B, newTree newFunc, β int) {
+		_, values := randomTree(b, newTree, β)
+		b.ResetTimer()
+		tree := newTree(β)
+		for _, v := range values {
+			tree.Insert(v.Key, v.Value)
+		}
+	})
 }
 
 func BenchmarkInsertOrdered(b *testing.B) {
-	for _, β := range balances {
-		b.Run(fmt.Sprintf("β=%d", β), func(b *testing.B) {
-			tree := bench.New(β)
-			for i := 1; i <= b.N; i++ {
-				tree.Insert(i, i)
-			}
-		})
-	}
+	runBalances(b, func(b *testing.B, newTree newFunc, β int) {
+		tree := newTree(β)
+		for i := 1; i <= b.N; i++ {
+			tree.Insert(i, i)
+		}
+	})
 }
 
 func BenchmarkRemoveRandom(b *testing.B) {
-	for _, β := range balances {
-		b.Run(fmt.Sprintf("β=%d", β), func(b *testing.B) {
-			tree, values := randomTree(b, β)
-			b.ResetTimer()
-			for _, v := range values {
-				tree.Remove(v.Key)
-			}
-		})
-	}
+	runBalances(b, func(b *testing.B, newTree newFunc, β int) {
+		tree, values := randomTree(b, newTree, β)
+		b.ResetTimer()
+		for _, v := range values {
+			tree.Remove(v.Key)
+		}
+	})
 }
 
 func BenchmarkRemoveOrdered(b *testing.B) {
-	for _, β := range balances {
-		b.Run(fmt.Sprintf("β=%d", β), func(b *testing.B) {
-			tree, values := randomTree(b, β)
-			sort.Sort(kvSlice(values))
-			b.ResetTimer()
-			for _, v := range values {
-				tree.Remove(v.Key)
-			}
-		})
-	}
+	runBalances(b, func(b *testing.B, newTree newFunc, β int) {
+		tree, values := randomTree(b, newTree, β)
+		sort.Sort(kvSlice(values))
+		b.ResetTimer()
+		for _, v := range values {
+			tree.Remove(v.Key)
+		}
+	})
 }
 
 func BenchmarkLookup(b *testing.B) {
-	for _, β := range balances {
-		b.Run(fmt.Sprintf("β=%d", β), func(b *testing.B) {
-			tree, values := randomTree(b, β)
-			b.ResetTimer()
-			for _, v := range values {
-				tree.Lookup(v.Key)
+	runBalances(b, func(b *testing.B, newTree newFunc, β int) {
+		tree, values := randomTree(b, newTree, β)
+		b.ResetTimer()
+		for _, v := range values {
+			tree.Lookup(v.Key)
+		}
+	})
+}
+
+// Tree size and batch sizes (as divisors of the tree size) for the batch
+// benchmarks. Each batch size is measured both with the batch method and with
+// a loop over the corresponding single-key method, to show the point at which
+// rebuilding the tree once is faster than updating it one key at a time.
+const batchTreeSize = 10000
+
+var batchDivisors = []int{100, 10, 5, 4, 3, 2, 1}
+
+// runBatches runs loop and batch as sub-benchmarks for each balance and batch
+// size. Each iteration applies one of them to a batch of the given size, made
+// by newBatch, and a fresh tree with the contents of base.
+func runBatches[T any](b *testing.B, base []bench.KV, newBatch func(m int) []T, loop, batch func(*bench.Tree, []T)) {
+	for _, β := range []int{0, 200, 500} {
+		for _, d := range batchDivisors {
+			items := newBatch(batchTreeSize / d)
+			for _, m := range []struct {
+				name  string
+				apply func(*bench.Tree, []T)
+			}{{"loop", loop}, {"batch", batch}} {
+				b.Run(fmt.Sprintf("β=%d/m=n÷%d/%s", β, d, m.name), func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						b.StopTimer()
+						tree := bench.New(β, base...)
+						b.StartTimer()
+						m.apply(tree, items)
+					}
+				})
 			}
-		})
+		}
 	}
 }
 
+func randomKVs(rng *rand.Rand, n int) []bench.KV {
+	kvs := make([]bench.KV, n)
+	for i := range kvs {
+		kvs[i].Key = rng.Intn(math.MaxInt32)
+	}
+	return kvs
+}
+
+func BenchmarkInsertBatch(b *testing.B) {
+	rng := rand.New(rand.NewSource(benchSeed))
+	base := randomKVs(rng, batchTreeSize)
+	runBatches(b, base, func(m int) []bench.KV { return randomKVs(rng, m) },
+		func(tree *bench.Tree, kvs []bench.KV) {
+			for _, kv := range kvs {
+				tree.Insert(kv.Key, kv.Value)
+			}
+		},
+		func(tree *bench.Tree, kvs []bench.KV) { tree.InsertAll(kvs...) },
+	)
+}
+
+func BenchmarkRemoveBatch(b *testing.B) {
+	rng := rand.New(rand.NewSource(benchSeed))
+	base := randomKVs(rng, batchTreeSize)
+	runBatches(b, base, func(m int) []bench.Key {
+		// Choose the keys to remove from among the keys of the tree.
+		keys := make([]bench.Key, m)
+		for i, j := range rng.Perm(len(base))[:m] {
+			keys[i] = base[j].Key
+		}
+		return keys
+	},
+		func(tree *bench.Tree, keys []bench.Key) {
+			for _, key := range keys {
+				tree.Remove(key)
+			}
+		},
+		func(tree *bench.Tree, keys []bench.Key) { tree.RemoveAll(keys...) },
+	)
+}
+
 type kvSlice []bench.KV
 
 func (s kvSlice) Len() int           { return len(s) }
@@ -0,0 +1,217 @@
+package generic
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// A Monoid defines an aggregate value of type A over the key/value pairs of a
+// tree, such as a sum or a maximum. Combine must be associative, and Identity
+// must be an identity for Combine:
+//
+//	Combine(Identity, a) == Combine(a, Identity) == a
+//	Combine(Combine(a, b), c) == Combine(a, Combine(b, c))
+//
+// Combine need not be commutative: aggregates are always combined in order of
+// key. Measure returns the aggregate of a single key/value pair.
+type Monoid[K, V, A any] struct {
+	Identity A
+	Combine  func(a, b A) A
+	Measure  func(KV[K, V]) A
+}
+
+// An augmenter maintains the aggregates stored in the nodes of a tree. The
+// nodes of an augmented tree are aggNodes, which the augmenter allocates.
+type augmenter[K, V any] interface {
+	// newNode returns a new augmented node with the contents of n.
+	newNode(n node[K, V]) *node[K, V]
+
+	// copy returns a new augmented node with the contents of n.
+	copy(n *node[K, V]) *node[K, V]
+
+	// update recomputes the aggregate of n from its key and value and the
+	// aggregates of its children. The caller must own n.
+	update(n *node[K, V])
+
+	// aggregate returns the aggregate of n, for diagnostics.
+	aggregate(n *node[K, V]) any
+}
+
+// An aggNode is the layout of the nodes of a tree augmented with a monoid
+// whose aggregates have type A. It has room for a subtree count, so that an
+// augmented tree can also be ranked.
+type aggNode[K, V, A any] struct {
+	rankedNode[K, V]
+	agg A // the aggregate of the subtree rooted at this node
+}
+
+// augmented returns the aggNode containing n. All the nodes of a tree
+// augmented with a monoid are aggNodes for the type A of its aggregates, since
+// Augment copies them; augmented panics if n is not an aggNode at all.
+func augmented[K, V, A any](n *node[K, V]) *aggNode[K, V, A] {
+	if n.layout() != aggLayout {
+		panic(fmt.Sprintf("node %v has %v layout, with no aggregate", n.key, n.layout()))
+	}
+	return (*aggNode[K, V, A])(unsafe.Pointer(n))
+}
+
+// newNode implements the augmenter interface.
+func (m *Monoid[K, V, A]) newNode(n node[K, V]) *node[K, V] {
+	a := &aggNode[K, V, A]{rankedNode: rankedNode[K, V]{node: n, count: 1}}
+	return &a.node
+}
+
+// copy implements the augmenter interface.
+func (m *Monoid[K, V, A]) copy(n *node[K, V]) *node[K, V] {
+	a := *augmented[K, V, A](n)
+	return &a.node
+}
+
+// update implements the augmenter interface.
+func (m *Monoid[K, V, A]) update(n *node[K, V]) {
+	self := m.Measure(KV[K, V]{Key: n.key, Value: n.value})
+	augmented[K, V, A](n).agg = m.Combine(m.Combine(m.value(n.left), self), m.value(n.right))
+}
+
+// aggregate implements the augmenter interface.
+func (m *Monoid[K, V, A]) aggregate(n *node[K, V]) any { return m.value(n) }
+
+// value returns the aggregate of the subtree rooted at n.
+func (m *Monoid[K, V, A]) value(n *node[K, V]) A {
+	if n == nil {
+		return m.Identity
+	}
+	return augmented[K, V, A](n).agg
+}
+
+// An AggTree is a Tree augmented with a monoid, so that the aggregate of the
+// key/value pairs in any range of keys can be computed in O(lg n) time. Use
+// Augment to construct an AggTree.
+//
+// Each node of the tree records the aggregate of its subtree. These are kept
+// current by all the methods that modify the tree, including rebuilds; the
+// cost is O(1) calls to the functions of the monoid for each node visited.
+type AggTree[K, V, A any] struct {
+	*Tree[K, V]
+	m *Monoid[K, V, A]
+}
+
+// Augment attaches m to t, and returns an AggTree for computing aggregates
+// over t. This takes O(n) time to copy the existing nodes of t into a layout
+// with room for aggregates of type A, and to compute them. Afterward, t may be
+// modified either directly or through the AggTree; either way, the aggregates
+// remain current. Trees derived from t by Clone, Split, Join, and the set
+// operations share its monoid.
+//
+// A tree has at most one monoid. If t was already augmented, m replaces its
+// previous monoid, and any previous AggTree for t may no longer be used.
+//
+// Augment panics if m.Combine or m.Measure is nil.
+func Augment[K, V, A any](t *Tree[K, V], m Monoid[K, V, A]) *AggTree[K, V, A] {
+	if m.Combine == nil || m.Measure == nil {
+		panic("monoid is missing Combine or Measure")
+	}
+	mp := &m
+	t.completeRebuild()
+	t.aug = mp
+	t.root = t.relayout(t.root) // copy the nodes into the augmented layout
+	return &AggTree[K, V, A]{Tree: t, m: mp}
+}
+
+// monoid returns the monoid of a, and panics if a is no longer valid.
+func (a *AggTree[K, V, A]) monoid() *Monoid[K, V, A] {
+	if a.Tree.aug != augmenter[K, V](a.m) {
+		panic("tree has been augmented with a different monoid")
+	}
+	return a.m
+}
+
+// Total returns the aggregate of all the key/value pairs in the tree, in
+// constant time. If the tree is empty, Total returns the identity.
+func (a *AggTree[K, V, A]) Total() A { return a.monoid().value(a.root) }
+
+// Aggregate returns the aggregate of the key/value pairs in the tree whose
+// keys are between lo and hi, in order of key. The bounds b specify whether lo
+// and hi are themselves included in the range, as for InorderRange. If the
+// range is empty, Aggregate returns the identity.
+func (a *AggTree[K, V, A]) Aggregate(lo, hi K, b Bounds) A {
+	m := a.monoid()
+	aboveLo := func(key K) bool {
+		c := a.compare(key, lo)
+		return c > 0 || (c == 0 && b&IncludeLo != 0)
+	}
+	belowHi := func(key K) bool {
+		c := a.compare(key, hi)
+		return c < 0 || (c == 0 && b&IncludeHi != 0)
+	}
+	measure := func(n *node[K, V]) A { return m.Measure(KV[K, V]{Key: n.key, Value: n.value}) }
+
+	// Find the highest node in the range. All the keys in its right subtree
+	// are above lo, and all the keys in its left subtree are below hi.
+	top := a.root
+	for top != nil {
+		if !aboveLo(top.key) {
+			top = top.right
+		} else if !belowHi(top.key) {
+			top = top.left
+		} else {
+			break
+		}
+	}
+	if top == nil {
+		return m.Identity
+	}
+
+	// Collect the part of the range in the left subtree, from right to left.
+	left := m.Identity
+	for cur := top.left; cur != nil; {
+		if aboveLo(cur.key) {
+			left = m.Combine(m.Combine(measure(cur), m.value(cur.right)), left)
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	// Collect the part of the range in the right subtree, from left to right.
+	right := m.Identity
+	for cur := top.right; cur != nil; {
+		if belowHi(cur.key) {
+			right = m.Combine(right, m.Combine(m.value(cur.left), measure(cur)))
+			cur = cur.right
+		} else {
+			cur = cur.left
+		}
+	}
+	return m.Combine(m.Combine(left, measure(top)), right)
+}
+
+// InorderPrune traverses the tree inorder, skipping each subtree whose
+// aggregate does not satisfy keep, and invokes f for each key of the remaining
+// subtrees until either f returns false or no further keys are available.
+//
+// Because a skipped subtree is not visited at all, InorderPrune can find the
+// keys of interest in time proportional to their number times the height of
+// the tree, provided keep(Combine(x, y)) is false only if both keep(x) and
+// keep(y) are false. For example, if the aggregate is a maximum, keep can
+// select subtrees whose maximum exceeds a threshold. Keys in a subtree that
+// satisfies keep are not otherwise filtered, so f may be called with keys
+// that do not themselves satisfy keep.
+func (a *AggTree[K, V, A]) InorderPrune(keep func(A) bool, f func(KV[K, V]) bool) {
+	m := a.monoid()
+	var stack []*node[K, V]
+	for cur := a.root; cur != nil || len(stack) != 0; cur = cur.right {
+		// Descend only into subtrees that satisfy keep.
+		for ; cur != nil && keep(m.value(cur)); cur = cur.left {
+			stack = append(stack, cur)
+		}
+		if len(stack) == 0 {
+			return
+		}
+		cur = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !f(KV[K, V]{Key: cur.key, Value: cur.value}) {
+			return
+		}
+	}
+}
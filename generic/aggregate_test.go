@@ -0,0 +1,166 @@
+package generic
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// checkAggs verifies that the aggregates of the nodes rooted at n are
+// consistent with m, and returns the aggregate of n.
+func checkAggs[K, V, A comparable](t *testing.T, m *Monoid[K, V, A], n *node[K, V]) A {
+	t.Helper()
+	if n == nil {
+		return m.Identity
+	}
+	left, right := checkAggs(t, m, n.left), checkAggs(t, m, n.right)
+	want := m.Combine(m.Combine(left, m.Measure(KV[K, V]{Key: n.key, Value: n.value})), right)
+	if got := augmented[K, V, A](n).agg; got != want {
+		t.Errorf("Node %v: aggregate is %v, want %v", n.key, got, want)
+	}
+	return want
+}
+
+// sumValues is a monoid that sums the values of a tree.
+var sumValues = Monoid[int, int, int]{
+	Combine: func(a, b int) int { return a + b },
+	Measure: func(kv KV[int, int]) int { return kv.Value },
+}
+
+// joinKeys is a non-commutative monoid that concatenates the keys of a tree.
+var joinKeys = Monoid[int, int, string]{
+	Combine: func(a, b string) string { return a + b },
+	Measure: func(kv KV[int, int]) string { return strconv.Itoa(kv.Key) + "," },
+}
+
+// bruteAggregate computes the aggregate of the keys in range by scanning.
+func bruteAggregate[A any](tree *Tree[int, int], m Monoid[int, int, A], lo, hi int, b Bounds) A {
+	out := m.Identity
+	tree.Inorder(func(kv KV[int, int]) bool {
+		if (kv.Key > lo || (kv.Key == lo && b&IncludeLo != 0)) &&
+			(kv.Key < hi || (kv.Key == hi && b&IncludeHi != 0)) {
+			out = m.Combine(out, m.Measure(kv))
+		}
+		return true
+	})
+	return out
+}
+
+func TestAggregate(t *testing.T) {
+	for _, β := range []int{0, 250, 1000} {
+		for _, multi := range []bool{false, true} {
+			tree := New[int, int](β)
+			tree.SetMulti(multi)
+			sum := Augment(tree, sumValues)
+
+			rng := rand.New(rand.NewSource(int64(β)))
+			for i := 0; i < 1500; i++ {
+				key := rng.Intn(200)
+				switch op := rng.Intn(10); {
+				case op < 5:
+					tree.Insert(key, rng.Intn(100))
+				case op < 7:
+					sum.Replace(key, rng.Intn(100))
+				default:
+					tree.Remove(key)
+				}
+			}
+			checkTree(t, tree)
+			checkAggs(t, sum.m, tree.root)
+
+			want := bruteAggregate(tree, sumValues, -1, 1000, Closed)
+			if got := sum.Total(); got != want {
+				t.Errorf("β=%d multi=%v: Total: got %d, want %d", β, multi, got, want)
+			}
+			for i := 0; i < 200; i++ {
+				lo, hi := rng.Intn(220)-10, rng.Intn(220)-10
+				b := Bounds(rng.Intn(4))
+				want := bruteAggregate(tree, sumValues, lo, hi, b)
+				if got := sum.Aggregate(lo, hi, b); got != want {
+					t.Errorf("β=%d multi=%v: Aggregate(%d, %d, %d): got %d, want %d",
+						β, multi, lo, hi, b, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestAggregateOrder(t *testing.T) {
+	tree := intTree(0, false, intRange(0, 100, 1))
+	cat := Augment(tree, joinKeys)
+	for lo := -1; lo <= 100; lo += 7 {
+		for hi := lo; hi <= 101; hi += 5 {
+			want := bruteAggregate(tree, joinKeys, lo, hi, HalfOpen)
+			if got := cat.Aggregate(lo, hi, HalfOpen); got != want {
+				t.Errorf("Aggregate(%d, %d): got %q, want %q", lo, hi, got, want)
+			}
+		}
+	}
+	if got := cat.Aggregate(5, 5, HalfOpen); got != "" {
+		t.Errorf("Aggregate(5, 5): got %q, want empty", got)
+	}
+	if got := cat.Aggregate(5, 5, Closed); got != "5," {
+		t.Errorf("Aggregate(5, 5, Closed): got %q, want %q", got, "5,")
+	}
+}
+
+func TestAggregateDerived(t *testing.T) {
+	tree := intTree(200, true, intRange(0, 300, 1))
+	sum := Augment(tree, sumValues)
+	total := sum.Total()
+
+	// Clones share the monoid, and their aggregates are independent.
+	cp := tree.Clone()
+	for i := 0; i < 300; i += 3 {
+		tree.Remove(i)
+	}
+	checkAggs(t, sum.m, tree.root)
+	checkAggs(t, sum.m, cp.root)
+	if got := (&AggTree[int, int, int]{Tree: cp, m: sum.m}).Total(); got != total {
+		t.Errorf("Clone Total: got %d, want %d", got, total)
+	}
+
+	// Split, Join, and the set operations preserve aggregates.
+	lo, hi := cp.Split(150)
+	checkAggs(t, sum.m, lo.root)
+	checkAggs(t, sum.m, hi.root)
+	plain := intTree(0, false, intRange(300, 310, 1))
+	joined := Join(Join(hi, lo), plain)
+	checkTree(t, joined)
+	checkAggs(t, sum.m, joined.root)
+	if got, want := sum.m.value(joined.root), total+3045; got != want {
+		t.Errorf("Joined total: got %d, want %d", got, want)
+	}
+	for _, res := range []*Tree[int, int]{
+		joined.Union(tree, nil), joined.Intersect(tree), joined.Difference(tree),
+	} {
+		checkAggs(t, sum.m, res.root)
+	}
+
+	// Decoding into an augmented tree computes aggregates.
+	data, err := joined.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	dec := Augment(New[int, int](0), sumValues)
+	if err := dec.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	checkAggs(t, dec.m, dec.root)
+
+	// Replacing the monoid with one of another type copies the nodes, so a
+	// clone sharing them keeps its aggregates.
+	snap := tree.Clone()
+	cat := Augment(tree, joinKeys)
+	checkTree(t, tree)
+	checkAggs(t, cat.m, tree.root)
+	checkAggs(t, sum.m, snap.root)
+
+	// Replacing the monoid invalidates an old AggTree.
+	defer func() {
+		if x := recover(); x == nil {
+			t.Error("Total on a replaced monoid did not panic")
+		}
+	}()
+	sum.Total()
+}
@@ -0,0 +1,51 @@
+package generic
+
+// Rebalance rebuilds t into a perfectly balanced tree, in time proportional
+// to its size, regardless of whether its shape requires it. This is useful
+// after a burst of removals has left t deeper than its size warrants, but not
+// yet small enough for a removal to trigger a rebuild. Rebalance also
+// completes any deamortized rebuild in progress; see SetRebuildQuantum.
+func (t *Tree[K, V]) Rebalance() {
+	if t.root != nil {
+		t.root = t.rewrite(t.root, t.size, 0)
+	}
+	t.max, t.excess, t.loose, t.pending = t.size, 0, 0, nil
+	t.mods++
+}
+
+// SetBalance sets the balancing factor of t to 0 ≤ β ≤ 1000, with the same
+// meaning as for New. If rebalance is true, t is then rebuilt as if by
+// Rebalance.
+//
+// Otherwise, the new factor governs subsequent updates, but the existing
+// shape of t is kept. If β is stricter than before, t may be deeper than the
+// new factor allows until an update rebuilds it; Stats reports the excess.
+// If t is deamortized, an incremental rebuild is scheduled to remove it, and
+// any rebuild already in progress starts over, paced for the new factor.
+//
+// SetBalance panics if β < 0 or β > 1000.
+func (t *Tree[K, V]) SetBalance(β int, rebalance bool) {
+	if β < 0 || β > maxBalance {
+		panic("β out of range")
+	}
+	old := t.limit
+	t.β, t.limit = β, limitFunc(β)
+	if rebalance {
+		t.Rebalance()
+		return
+	}
+	if t.max != 0 {
+		// The excess from deferred rebuilds was paced for the old factor, so
+		// count it with the excess from the change of factor instead.
+		t.loose = max(0, old(t.max)+t.excess+t.loose-t.limit(t.max))
+		t.excess = 0
+	}
+	if r := t.pending; r != nil && r.b != nil {
+		// A rebuild in progress was paced for the old factor, and may already
+		// have logged more updates than the new one allows; start it over.
+		t.pending = &rebuilder[K, V]{cause: r.cause}
+	}
+	if t.loose != 0 && t.quantum > 0 {
+		t.deferRebuild(byOther)
+	}
+}
@@ -0,0 +1,106 @@
+package generic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRebalance(t *testing.T) {
+	tree := intTree(300, false, intRange(0, 1000, 1))
+	snap := tree.Clone()
+	for _, k := range intRange(0, 1000, 2) {
+		tree.Remove(k)
+	}
+	before := tree.Stats()
+	if before.Max != 1000 {
+		t.Fatalf("Removals rebuilt the tree: %+v", before)
+	}
+
+	tree.Rebalance()
+	s := tree.Stats()
+	if s.Max != s.Size || s.Height > tree.limit(s.Size)+1 {
+		t.Errorf("After Rebalance: got %+v, want max %d and height ≤ %d", s, s.Size, tree.limit(s.Size)+1)
+	}
+	if s.NodesRebuilt != before.NodesRebuilt+500 {
+		t.Errorf("Rebalance: got %d nodes rebuilt, want %d", s.NodesRebuilt, before.NodesRebuilt+500)
+	}
+	checkTree(t, tree)
+	if diff := cmp.Diff(intRange(1, 1000, 2), allKeys(tree)); diff != "" {
+		t.Errorf("Keys after Rebalance (-want, +got)\n%s", diff)
+	}
+	if diff := cmp.Diff(intRange(0, 1000, 1), allKeys(snap)); diff != "" {
+		t.Errorf("Snapshot changed (-want, +got)\n%s", diff)
+	}
+
+	// Rebalancing an empty tree is harmless.
+	empty := New[int, int](0)
+	empty.Rebalance()
+	checkTree(t, empty)
+}
+
+func TestSetBalance(t *testing.T) {
+	// Ordered insertion with no rebalancing makes a list.
+	tree := intTree(1000, true, intRange(0, 200, 1))
+	if h := tree.Stats().Height; h != 200 {
+		t.Fatalf("Height: got %d, want 200", h)
+	}
+
+	// Tightening the factor without rebalancing keeps the shape, but records
+	// the excess depth.
+	tree.SetBalance(0, false)
+	s := tree.Stats()
+	if s.Height != 200 || s.Excess == 0 {
+		t.Errorf("SetBalance(0, false): got %+v, want height 200 with excess", s)
+	}
+	checkTree(t, tree)
+
+	// Subsequent updates use the new factor.
+	for _, k := range intRange(200, 400, 1) {
+		tree.Insert(k, k)
+	}
+	checkTree(t, tree)
+	if s := tree.Stats(); s.InsertRebuilds == 0 {
+		t.Errorf("No rebuilds after SetBalance(0): %+v", s)
+	}
+
+	tree.SetBalance(0, true)
+	if s := tree.Stats(); s.Excess != 0 || s.Height > tree.limit(s.Size)+1 {
+		t.Errorf("SetBalance(0, true): got %+v, want height ≤ %d", s, tree.limit(s.Size)+1)
+	}
+	checkTree(t, tree)
+
+	// Loosening the factor never leaves an excess.
+	tree.SetBalance(500, false)
+	if s := tree.Stats(); s.Excess != 0 {
+		t.Errorf("SetBalance(500, false): got excess %d, want 0", s.Excess)
+	}
+	if diff := cmp.Diff(intRange(0, 400, 1), allKeys(tree)); diff != "" {
+		t.Errorf("Keys (-want, +got)\n%s", diff)
+	}
+
+	// A deamortized tree rebuilds incrementally.
+	tree.SetBalance(1000, false)
+	tree.SetRebuildQuantum(10)
+	for _, k := range intRange(400, 600, 1) {
+		tree.Insert(k, k)
+	}
+	tree.SetBalance(0, false)
+	if s := tree.Stats(); !s.Rebuilding {
+		t.Errorf("SetBalance(0, false): no rebuild scheduled: %+v", s)
+	}
+	for i := 0; i < 100 && tree.Stats().Rebuilding; i++ {
+		tree.Replace(i, i)
+		checkTree(t, tree)
+	}
+	if s := tree.Stats(); s.Rebuilding || s.Height > tree.limit(s.Size)+s.Excess+1 {
+		t.Errorf("Incremental rebuild did not finish: %+v", s)
+	}
+
+	defer func() {
+		if x := recover(); x == nil {
+			t.Error("SetBalance(1001) did not panic")
+		}
+	}()
+	tree.SetBalance(1001, false)
+}
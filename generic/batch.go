@@ -0,0 +1,274 @@
+package generic
+
+import "slices"
+
+// InsertAll adds each of the given key/value pairs to t, as if by Insert, and
+// returns the number of new nodes added. If a key is already present in t, or
+// occurs more than once in kvs, the first value for that key is kept. If t is
+// a multimap, all the pairs are added, in order after any existing nodes with
+// the same keys.
+//
+// If the batch is large relative to t, InsertAll merges the sorted batch with
+// the existing contents of t and rebuilds the tree once, in O(n + m lg m) time
+// for a tree of size n and a batch of size m, rather than inserting the pairs
+// one at a time with any intermediate rebuilds that entails.
+func (t *Tree[K, V]) InsertAll(kvs ...KV[K, V]) int {
+	if !t.mergeBatch(len(kvs)) {
+		var nadd int
+		for _, kv := range kvs {
+			if t.Insert(kv.Key, kv.Value) {
+				nadd++
+			}
+		}
+		return nadd
+	}
+	return t.mergeInsert(kvs)
+}
+
+// mergeInsert implements InsertAll by merging kvs with the contents of t and
+// rebuilding.
+func (t *Tree[K, V]) mergeInsert(kvs []KV[K, V]) int {
+	// Order the batch by key, preserving the order of equal keys.
+	order := make([]int, len(kvs))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if c := t.compare(kvs[a].Key, kvs[b].Key); c != 0 {
+			return c
+		}
+		return a - b
+	})
+
+	// Merge the batch with the existing nodes in a single inorder pass. A new
+	// key goes before an existing node only if its key is smaller, so that in
+	// a multimap new nodes follow existing nodes with the same key.
+	out := make([]*node[K, V], 0, t.size+len(kvs))
+	j := 0
+	emit := func(kv KV[K, V]) {
+		if !t.multi && j > 0 && t.compare(kvs[order[j-1]].Key, kv.Key) == 0 {
+			return // key is duplicated in the batch
+		}
+		out = append(out, t.newNode(kv.Key, kv.Value))
+	}
+	t.root.walk(func(n *node[K, V]) {
+		for ; j < len(order) && t.compare(kvs[order[j]].Key, n.key) < 0; j++ {
+			emit(kvs[order[j]])
+		}
+		out = append(out, t.mut(n))
+		for ; !t.multi && j < len(order) && t.compare(kvs[order[j]].Key, n.key) == 0; j++ {
+			// key is already present
+		}
+	})
+	for ; j < len(order); j++ {
+		emit(kvs[order[j]])
+	}
+
+	nadd := len(out) - t.size
+	if nadd != 0 {
+		t.rebuildFrom(out)
+	}
+	return nadd
+}
+
+// RemoveAll removes all the nodes with each of the given keys from t, and
+// returns the number of nodes removed. Keys not present in t are ignored.
+//
+// If the batch is large relative to t, RemoveAll filters the existing contents
+// of t against the sorted batch and rebuilds the tree once, in O(n + m lg m)
+// time for a tree of size n and a batch of size m, rather than removing the
+// keys one at a time.
+func (t *Tree[K, V]) RemoveAll(keys ...K) int {
+	if !t.mergeBatch(len(keys)) {
+		var nr int
+		for _, key := range keys {
+			for t.Remove(key) {
+				nr++
+				if !t.multi {
+					break // there are no other nodes with this key
+				}
+			}
+		}
+		return nr
+	}
+	return t.mergeRemove(keys)
+}
+
+// mergeRemove implements RemoveAll by filtering the contents of t against keys
+// and rebuilding.
+func (t *Tree[K, V]) mergeRemove(keys []K) int {
+	batch := slices.Clone(keys)
+	slices.SortFunc(batch, t.compare)
+
+	out := make([]*node[K, V], 0, t.size)
+	j := 0
+	t.root.walk(func(n *node[K, V]) {
+		for j < len(batch) && t.compare(batch[j], n.key) < 0 {
+			j++
+		}
+		if j == len(batch) || t.compare(batch[j], n.key) != 0 {
+			out = append(out, t.mut(n))
+		}
+	})
+
+	nr := t.size - len(out)
+	if nr != 0 {
+		t.rebuildFrom(out)
+	}
+	return nr
+}
+
+// mergeBatch reports whether a batch operation on m keys should merge the
+// batch with the contents of t and rebuild, rather than updating t one key at
+// a time. Although updating one key at a time costs O(m lg n) for a tree of
+// size n, while merging costs O(n + m lg m), the merge touches every node of
+// the tree and is memory-bound, so in practice it only pays off once the batch
+// is a substantial fraction of the tree. See the batch benchmarks in the bench
+// package.
+func (t *Tree[K, V]) mergeBatch(m int) bool {
+	return m != 0 && batchFactor*m >= t.size
+}
+
+// batchFactor is the ratio of tree size to batch size above which batch
+// operations update the tree one key at a time.
+const batchFactor = 3
+
+// rebuildFrom replaces the contents of t with nodes, which must be owned by t
+// and in order, building a balanced tree.
+func (t *Tree[K, V]) rebuildFrom(nodes []*node[K, V]) {
+	start := t.rebuildStart()
+	t.root = t.extract(nodes)
+	t.rebuilt(len(nodes), 0, start)
+	t.size, t.max = len(nodes), len(nodes)
+	t.pending, t.excess, t.loose = nil, 0, 0
+	t.mods++
+}
+
+// RemoveRange removes all the nodes with keys between lo and hi from t, and
+// returns the number of nodes removed. The bounds b specify whether lo and hi
+// are themselves included in the range, as for InorderRange.
+//
+// RemoveRange visits only the nodes in the range and the paths to its
+// endpoints, taking O(lg n + k) time to remove k of n nodes. If the tree has
+// shrunk too far to remain balanced, it is rebuilt, as if by a removal.
+func (t *Tree[K, V]) RemoveRange(lo, hi K, b Bounds) int {
+	// Classify a key as below (-1), inside (0), or above (1) the range.
+	rel := func(key K) int {
+		if c := t.compare(key, lo); c < 0 || (c == 0 && b&IncludeLo == 0) {
+			return -1
+		} else if c := t.compare(key, hi); c > 0 || (c == 0 && b&IncludeHi == 0) {
+			return 1
+		}
+		return 0
+	}
+	t.completeRebuild()
+	root, nr := t.removeRange(t.root, rel)
+	if nr != 0 {
+		t.root = root
+		t.mods++
+		t.setSize(t.size-nr, t.max)
+	}
+	return nr
+}
+
+// removeRange removes the nodes whose keys are inside the range defined by rel
+// from the subtree under n, returning the modified tree and the number of
+// nodes removed.
+func (t *Tree[K, V]) removeRange(n *node[K, V], rel func(K) int) (*node[K, V], int) {
+	// Find the topmost node inside the range.
+	var path []step[K, V]
+	top := n
+	for top != nil {
+		r := rel(top.key)
+		if r == 0 {
+			break
+		}
+		path = append(path, step[K, V]{top, r > 0})
+		if r > 0 {
+			top = top.left
+		} else {
+			top = top.right
+		}
+	}
+	if top == nil {
+		return n, 0
+	}
+
+	// Only the topmost node inside the range can have nodes left on both
+	// sides; below it, one side of every node inside the range is entirely
+	// inside the range too.
+	left, nl := t.trimRange(top.left, rel, -1)
+	right, nr := t.trimRange(top.right, rel, 1)
+	nr += nl + 1
+	if left == nil {
+		return t.relink(path, right), nr
+	} else if right == nil {
+		return t.relink(path, left), nr
+	}
+	// The smallest node remaining on the right takes the place of top.
+	rest, mid := t.popMin(right)
+	mid = t.mut(mid)
+	mid.left, mid.right = left, rest
+	t.fix(mid)
+	return t.relink(path, mid), nr
+}
+
+// trimRange removes the nodes whose keys are inside the range defined by rel
+// from the subtree under n, all of whose keys are either inside the range or
+// on the given side of it: below if side < 0, above if side > 0. Returns the
+// modified tree and the number of nodes removed.
+func (t *Tree[K, V]) trimRange(n *node[K, V], rel func(K) int, side int) (*node[K, V], int) {
+	// The nodes outside the range form a chain, each linked toward the range
+	// to the next. Each node inside the range is removed, together with its
+	// subtree on the side toward the range.
+	toward := side < 0 // the chain is linked by right children
+	var path []step[K, V]
+	var nr, cut int // cut is the length of path at the last removal
+	for n != nil {
+		if rel(n.key) == side {
+			path = append(path, step[K, V]{n, !toward})
+			if toward {
+				n = n.right
+			} else {
+				n = n.left
+			}
+			continue
+		}
+		if toward {
+			nr += 1 + t.sizeOf(n.right)
+			n = n.left
+		} else {
+			nr += 1 + t.sizeOf(n.left)
+			n = n.right
+		}
+		cut = len(path)
+	}
+
+	// The nodes of the chain after the last removal are unchanged.
+	if cut < len(path) {
+		n = path[cut].n
+	}
+	return t.relink(path[:cut], n), nr
+}
+
+// RemoveIf removes from t all the nodes whose key/value pairs satisfy f, and
+// returns the number of nodes removed. RemoveIf calls f once for each node of
+// t, in order, and then rebuilds the tree from the remaining nodes, if any were
+// removed. The function f must not modify t.
+func (t *Tree[K, V]) RemoveIf(f func(KV[K, V]) bool) int {
+	out := make([]*node[K, V], 0, t.size)
+	t.root.walk(func(n *node[K, V]) {
+		if !f(KV[K, V]{Key: n.key, Value: n.value}) {
+			out = append(out, n)
+		}
+	})
+
+	nr := t.size - len(out)
+	if nr != 0 {
+		for i, n := range out {
+			out[i] = t.mut(n)
+		}
+		t.rebuildFrom(out)
+	}
+	return nr
+}
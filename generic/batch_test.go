@@ -0,0 +1,203 @@
+package generic
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInsertAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Batch sizes both below and above the merge threshold.
+	for _, m := range []int{5, 100, 1000} {
+		for _, ranked := range []bool{false, true} {
+			tree := intTree(100, ranked, intRange(0, 600, 2))
+			sum := Augment(tree, sumValues)
+			snap := tree.Clone()
+			want := make(map[int]int)
+			tree.Inorder(func(kv KV[int, int]) bool {
+				want[kv.Key] = kv.Value
+				return true
+			})
+
+			var batch []KV[int, int]
+			wantAdd := 0
+			for i := 0; i < m; i++ {
+				kv := KV[int, int]{Key: rng.Intn(1200), Value: -i}
+				batch = append(batch, kv)
+				if _, ok := want[kv.Key]; !ok {
+					want[kv.Key] = kv.Value // the first value for a key wins
+					wantAdd++
+				}
+			}
+			if got := tree.InsertAll(batch...); got != wantAdd {
+				t.Errorf("m=%d: InsertAll: got %d, want %d", m, got, wantAdd)
+			}
+			checkTree(t, tree)
+			checkAggs(t, sum.m, tree.root)
+			if diff := cmp.Diff(mapContents(want), contents(tree)); diff != "" {
+				t.Errorf("m=%d: contents (-want, +got)\n%s", m, diff)
+			}
+			if diff := cmp.Diff(intRange(0, 600, 2), allKeys(snap)); diff != "" {
+				t.Errorf("m=%d: snapshot changed (-want, +got)\n%s", m, diff)
+			}
+		}
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, m := range []int{5, 100, 1000} {
+		tree := intTree(100, true, intRange(0, 600, 1))
+		snap := tree.Clone()
+		want := make(map[int]int)
+		for _, k := range intRange(0, 600, 1) {
+			want[k] = k
+		}
+
+		var keys []int
+		wantRemoved := 0
+		for i := 0; i < m; i++ {
+			key := rng.Intn(700)
+			keys = append(keys, key)
+			if _, ok := want[key]; ok {
+				delete(want, key)
+				wantRemoved++
+			}
+		}
+		orig := append([]int(nil), keys...)
+		if got := tree.RemoveAll(keys...); got != wantRemoved {
+			t.Errorf("m=%d: RemoveAll: got %d, want %d", m, got, wantRemoved)
+		}
+		checkTree(t, tree)
+		if diff := cmp.Diff(mapContents(want), contents(tree)); diff != "" {
+			t.Errorf("m=%d: contents (-want, +got)\n%s", m, diff)
+		}
+		if diff := cmp.Diff(orig, keys); diff != "" {
+			t.Errorf("m=%d: RemoveAll modified its argument (-want, +got)\n%s", m, diff)
+		}
+		if snap.Len() != 600 {
+			t.Errorf("m=%d: snapshot Len = %d, want 600", m, snap.Len())
+		}
+	}
+}
+
+func TestBatchMulti(t *testing.T) {
+	for _, m := range []int{2, 50} {
+		tree := New[int, int](0)
+		tree.SetMulti(true)
+		var model multiModel
+		for i := 0; i < 20; i++ {
+			tree.Insert(i%5, i)
+			model.insert(i%5, i)
+		}
+		var batch []KV[int, int]
+		for i := 0; i < m; i++ {
+			batch = append(batch, KV[int, int]{Key: (i * 3) % 7, Value: 100 + i})
+			model.insert((i*3)%7, 100+i)
+		}
+		if got := tree.InsertAll(batch...); got != m {
+			t.Errorf("m=%d: InsertAll: got %d, want %d", m, got, m)
+		}
+		checkTree(t, tree)
+		if diff := cmp.Diff([]KV[int, int](model), contents(tree)); diff != "" {
+			t.Errorf("m=%d: contents (-want, +got)\n%s", m, diff)
+		}
+
+		want := len(model.values(1)) + len(model.values(3))
+		if got := tree.RemoveAll(3, 1, 3, 99); got != want {
+			t.Errorf("m=%d: RemoveAll: got %d, want %d", m, got, want)
+		}
+		checkTree(t, tree)
+		if vs := treeValues(tree, 1); len(vs) != 0 {
+			t.Errorf("m=%d: values for 1 remain after RemoveAll: %v", m, vs)
+		}
+	}
+}
+
+func TestBatchCursor(t *testing.T) {
+	tree := intTree(0, false, intRange(0, 10, 1))
+	c := tree.First()
+	// This batch is large enough to merge, but changes nothing.
+	if tree.InsertAll(KV[int, int]{Key: 1}, KV[int, int]{Key: 3}, KV[int, int]{Key: 5}, KV[int, int]{Key: 7}) != 0 || !c.Valid() {
+		t.Error("InsertAll of existing keys invalidated a cursor")
+	}
+	if tree.InsertAll(KV[int, int]{Key: 10}, KV[int, int]{Key: 11}, KV[int, int]{Key: 12}, KV[int, int]{Key: 13}) != 4 || c.Valid() {
+		t.Error("InsertAll of new keys did not invalidate a cursor")
+	}
+}
+
+func TestRemoveRange(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 200; i++ {
+		ranked := i%2 == 0
+		tree := intTree(100, ranked, intRange(0, 300, 3))
+		sum := Augment(tree, sumValues)
+		snap := tree.Clone()
+
+		lo, hi, b := rng.Intn(320)-10, rng.Intn(320)-10, Bounds(rng.Intn(4))
+		var want []int
+		wantRemoved := 0
+		for _, k := range intRange(0, 300, 3) {
+			if (k > lo || (k == lo && b&IncludeLo != 0)) && (k < hi || (k == hi && b&IncludeHi != 0)) {
+				wantRemoved++
+			} else {
+				want = append(want, k)
+			}
+		}
+		if got := tree.RemoveRange(lo, hi, b); got != wantRemoved {
+			t.Errorf("RemoveRange(%d, %d, %d): got %d, want %d", lo, hi, b, got, wantRemoved)
+		}
+		checkTree(t, tree)
+		checkAggs(t, sum.m, tree.root)
+		if diff := cmp.Diff(want, allKeys(tree)); diff != "" {
+			t.Errorf("RemoveRange(%d, %d, %d): keys (-want, +got)\n%s", lo, hi, b, diff)
+		}
+		if snap.Len() != 100 {
+			t.Errorf("RemoveRange(%d, %d, %d): snapshot Len = %d, want 100", lo, hi, b, snap.Len())
+		}
+	}
+}
+
+func TestRemoveRangeMulti(t *testing.T) {
+	tree := New[int, int](0)
+	tree.SetMulti(true)
+	var model multiModel
+	for i := 0; i < 40; i++ {
+		tree.Insert(i%8, i)
+		model.insert(i%8, i)
+	}
+	want := len(model.values(2)) + len(model.values(3)) + len(model.values(4))
+	if got := tree.RemoveRange(2, 5, HalfOpen); got != want {
+		t.Errorf("RemoveRange: got %d, want %d", got, want)
+	}
+	model = append(model[:model.lower(2)], model[model.lower(5):]...)
+	checkTree(t, tree)
+	if diff := cmp.Diff([]KV[int, int](model), contents(tree)); diff != "" {
+		t.Errorf("Contents (-want, +got)\n%s", diff)
+	}
+}
+
+func TestRemoveIf(t *testing.T) {
+	for _, ranked := range []bool{false, true} {
+		tree := intTree(100, ranked, intRange(0, 100, 1))
+		sum := Augment(tree, sumValues)
+		snap := tree.Clone()
+
+		if got := tree.RemoveIf(func(KV[int, int]) bool { return false }); got != 0 {
+			t.Errorf("RemoveIf(none): got %d, want 0", got)
+		}
+		if got := tree.RemoveIf(func(kv KV[int, int]) bool { return kv.Key%3 != 0 }); got != 66 {
+			t.Errorf("RemoveIf: got %d, want 66", got)
+		}
+		checkTree(t, tree)
+		checkAggs(t, sum.m, tree.root)
+		if diff := cmp.Diff(intRange(0, 100, 3), allKeys(tree)); diff != "" {
+			t.Errorf("RemoveIf: keys (-want, +got)\n%s", diff)
+		}
+		if diff := cmp.Diff(intRange(0, 100, 1), allKeys(snap)); diff != "" {
+			t.Errorf("RemoveIf: snapshot changed (-want, +got)\n%s", diff)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package generic
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Check verifies the structural invariants of t, and returns an error that
+// describes the first violation found, or nil if t is valid. Check verifies
+// that:
+//
+//   - The keys of t are in order by its comparison function, and distinct
+//     unless t is a multimap.
+//   - The size of t equals the number of nodes, and does not exceed the
+//     high-water mark from which t rebuilds itself when it shrinks.
+//   - No node is deeper than the depth limit implied by the balancing factor
+//     of t for that high-water mark, plus any excess allowed by deamortized
+//     rebuilding (see SetRebuildQuantum) or by tightening the factor with
+//     SetBalance. The excess from deamortized rebuilding must not exceed the
+//     number of updates within which a rebuild is paced to finish.
+//   - No node that t shares with a clone has a child that t may modify in
+//     place.
+//   - Each node has room for the metadata t maintains: a subtree count if t
+//     is ranked, and an aggregate if t is augmented.
+//   - If t is ranked, the subtree count of each node is correct.
+//   - If t is augmented, the aggregate of each node is deeply equal to the
+//     aggregate recomputed from its children.
+//
+// Check takes O(n) time, and is intended for use in tests.
+func (t *Tree[K, V]) Check() error {
+	c := checker[K, V]{t: t, limit: -1}
+	if t.max != 0 {
+		c.limit = t.limit(t.max) + t.excess + t.loose
+		if g := t.pace(t.max); t.excess > g {
+			return fmt.Errorf("excess depth %d exceeds the rebuild pace %d", t.excess, g)
+		}
+	}
+	if err := c.visit(t.root); err != nil {
+		return err
+	}
+	if t.size != c.pos {
+		return fmt.Errorf("tree has %d nodes, but its size is %d", c.pos, t.size)
+	} else if t.max < t.size {
+		return fmt.Errorf("tree size %d exceeds its maximum size %d", t.size, t.max)
+	}
+	return nil
+}
+
+// A checker carries the state of an inorder traversal by Check.
+type checker[K, V any] struct {
+	t     *Tree[K, V]
+	limit int         // the maximum depth of a node
+	prev  *node[K, V] // the previous node in order, or nil
+	pos   int         // the position of the next node in order
+}
+
+// visit checks the nodes of the tree rooted at root, inorder.
+func (c *checker[K, V]) visit(root *node[K, V]) error {
+	type entry struct {
+		n     *node[K, V]
+		depth int
+	}
+	var stack []entry
+	push := func(n *node[K, V], depth int) error {
+		for ; n != nil; n, depth = n.left, depth+1 {
+			if depth > c.limit {
+				return fmt.Errorf("key %v at depth %d exceeds the depth limit %d for size %d", n.key, depth, c.limit, c.t.max)
+			}
+			stack = append(stack, entry{n, depth})
+		}
+		return nil
+	}
+	if err := push(root, 0); err != nil {
+		return err
+	}
+	for len(stack) != 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err := c.check(e.n); err != nil {
+			return err
+		} else if err := push(e.n.right, e.depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check checks n, which is the next node in order.
+func (c *checker[K, V]) check(n *node[K, V]) error {
+	t := c.t
+	if c.prev != nil && !inOrder(t.compare(c.prev.key, n.key), t.multi) {
+		return fmt.Errorf("key %v at position %d is out of order after %v", n.key, c.pos, c.prev.key)
+	}
+	if l, want := n.layout(), t.layout(); l < want {
+		return fmt.Errorf("key %v has %v layout, want %v", n.key, l, want)
+	}
+	if n.owner() != t.epoch {
+		// A node shared with another tree must not have children that t may
+		// modify in place, since the other tree would see the changes.
+		for _, kid := range []*node[K, V]{n.left, n.right} {
+			if kid != nil && kid.owner() == t.epoch {
+				return fmt.Errorf("key %v is shared, but its child %v is not", n.key, kid.key)
+			}
+		}
+	}
+	if t.ranked {
+		if want := 1 + n.left.weight() + n.right.weight(); n.weight() != want {
+			return fmt.Errorf("key %v has subtree count %d, want %d", n.key, n.weight(), want)
+		}
+	}
+	if t.aug != nil {
+		cp := t.aug.copy(n)
+		t.aug.update(cp)
+		if got, want := t.aug.aggregate(n), t.aug.aggregate(cp); !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("key %v has aggregate %v, want %v", n.key, got, want)
+		}
+	}
+	c.prev = n
+	c.pos++
+	return nil
+}
@@ -0,0 +1,124 @@
+package generic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	// Each test corrupts a valid tree, and Check must report the damage.
+	tests := []struct {
+		name    string
+		corrupt func(*Tree[int, int])
+		want    string
+	}{
+		{"Order", func(tree *Tree[int, int]) {
+			tree.root.left.key = 1000
+		}, "out of order"},
+		{"Duplicate", func(tree *Tree[int, int]) {
+			tree.root.left.key = tree.root.key
+		}, "out of order"},
+		{"Size", func(tree *Tree[int, int]) {
+			tree.size++
+		}, "size is 101"},
+		{"Max", func(tree *Tree[int, int]) {
+			tree.max = tree.size - 1
+		}, "exceeds its maximum size"},
+		{"Depth", func(tree *Tree[int, int]) {
+			n := tree.root
+			for n.right != nil {
+				n = n.right
+			}
+			for i := 0; i < 20; i++ {
+				n.right = tree.newNode(1000+i, 0)
+				n = n.right
+			}
+			tree.size += 20
+			tree.max = tree.size
+		}, "exceeds the depth limit"},
+		{"Shared", func(tree *Tree[int, int]) {
+			tree.root.epoch += 1 << layoutBits
+		}, "is shared"},
+		{"Layout", func(tree *Tree[int, int]) {
+			tree.ranked = true // without copying the nodes
+		}, "plain layout"},
+		{"Count", func(tree *Tree[int, int]) {
+			tree.SetRanked(true)
+			tree.root.right.ranked().count++
+		}, "subtree count"},
+		{"Aggregate", func(tree *Tree[int, int]) {
+			Augment(tree, sumValues)
+			tree.root.left.value++
+		}, "aggregate"},
+	}
+	for _, test := range tests {
+		tree := intTree(0, false, intRange(0, 100, 1))
+		if err := tree.Check(); err != nil {
+			t.Fatalf("Check on a valid tree: %v", err)
+		}
+		test.corrupt(tree)
+		if err := tree.Check(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	var empty Tree[int, int]
+	if err := empty.Check(); err != nil {
+		t.Errorf("Check on an empty tree: %v", err)
+	}
+	multi := New[int, int](0)
+	multi.SetMulti(true)
+	multi.Insert(1, 1)
+	multi.Insert(1, 2)
+	if err := multi.Check(); err != nil {
+		t.Errorf("Check on a multimap: %v", err)
+	}
+}
+
+func TestCheckLayout(t *testing.T) {
+	// Each change of ranking or augmentation copies the nodes of a tree into
+	// a layout with room for its metadata, without disturbing clones.
+	check := func(step string, trees ...*Tree[int, int]) {
+		t.Helper()
+		for i, tree := range trees {
+			if err := tree.Check(); err != nil {
+				t.Fatalf("%s: tree %d: Check: %v", step, i, err)
+			}
+		}
+	}
+	plain := intTree(100, false, intRange(0, 100, 1))
+	ranked := plain.Clone()
+	ranked.SetRanked(true)
+	ranked.Insert(100, 100)
+	check("SetRanked after Clone", plain, ranked)
+
+	unranked := ranked.Clone()
+	unranked.SetRanked(false)
+	unranked.Insert(101, 101) // a plain node in a tree of ranked nodes
+	unranked.SetRanked(true)
+	check("SetRanked off and on", plain, ranked, unranked)
+
+	aug := ranked.Clone()
+	Augment(aug, sumValues)
+	aug.SetRanked(false)
+	aug.Insert(102, 102)
+	aug.SetRanked(true)
+	check("Augment after Clone", plain, ranked, aug)
+
+	// A Join copies the halves whose layouts differ from the result.
+	lo, hi := plain.Clone().Split(50)
+	hi.SetRanked(true)
+	check("Join plain and ranked", plain, Join(lo, hi))
+
+	lo, hi = plain.Clone().Split(50)
+	Augment(hi, sumValues)
+	check("Join plain and augmented", plain, Join(lo, hi))
+
+	lo, hi = ranked.Clone().Split(50)
+	Augment(lo, joinKeys)
+	check("Join ranked and augmented", ranked, Join(lo, hi))
+
+	lo, hi = aug.Clone().Split(50)
+	Augment(hi, joinKeys)
+	check("Join different monoids", aug, Join(lo, hi))
+}
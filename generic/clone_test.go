@@ -0,0 +1,161 @@
+package generic
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// contents returns the key/value pairs of tree in order.
+func contents[K, V any](tree *Tree[K, V]) []KV[K, V] {
+	var out []KV[K, V]
+	tree.Inorder(func(kv KV[K, V]) bool {
+		out = append(out, kv)
+		return true
+	})
+	return out
+}
+
+// mapContents returns the key/value pairs of m in order of key.
+func mapContents(m map[int]int) []KV[int, int] {
+	var out []KV[int, int]
+	for k, v := range m {
+		out = append(out, KV[int, int]{Key: k, Value: v})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// nodeSet returns the set of nodes in the subtree rooted at n.
+func nodeSet[K, V any](n *node[K, V], into map[*node[K, V]]bool) map[*node[K, V]]bool {
+	if n != nil {
+		into[n] = true
+		nodeSet(n.left, into)
+		nodeSet(n.right, into)
+	}
+	return into
+}
+
+func TestClone(t *testing.T) {
+	rng := rand.New(rand.NewSource(8675309))
+
+	// Apply random changes to tree, recording them in model.
+	mutate := func(tree *Tree[int, int], model map[int]int, gen int) {
+		for i := 0; i < 100; i++ {
+			key := rng.Intn(300)
+			switch rng.Intn(4) {
+			case 0:
+				tree.Remove(key)
+				delete(model, key)
+			case 1:
+				if tree.Insert(key, gen) {
+					model[key] = gen
+				}
+			default:
+				tree.Replace(key, gen)
+				model[key] = gen
+			}
+		}
+	}
+	copyMap := func(m map[int]int) map[int]int {
+		out := make(map[int]int)
+		for k, v := range m {
+			out[k] = v
+		}
+		return out
+	}
+
+	for _, β := range []int{0, 200, 1000} {
+		for _, ranked := range []bool{false, true} {
+			tree := New[int, int](β)
+			tree.SetRanked(ranked)
+			model := make(map[int]int)
+
+			// Each generation, take a snapshot of the tree and a fork of it, then
+			// apply different random changes to the tree and the fork. Neither
+			// change may be visible in the other, nor in any snapshot.
+			type version struct {
+				tree *Tree[int, int]
+				want []KV[int, int]
+			}
+			var snaps []version
+			for gen := 0; gen < 12; gen++ {
+				snaps = append(snaps, version{tree: tree.Clone(), want: mapContents(model)})
+				fork, forkModel := tree.Clone(), copyMap(model)
+				mutate(tree, model, gen)
+				mutate(fork, forkModel, -gen)
+
+				vs := append(snaps,
+					version{tree: tree, want: mapContents(model)},
+					version{tree: fork, want: mapContents(forkModel)},
+				)
+				for i, v := range vs {
+					checkTree(t, v.tree)
+					if diff := cmp.Diff(v.want, contents(v.tree), cmpopts.EquateEmpty()); diff != "" {
+						t.Fatalf("β=%d gen %d: version %d contents (-want, +got)\n%s", β, gen, i, diff)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCloneSnapshot(t *testing.T) {
+	tree := New[int, int](100)
+	for i := 0; i < 1000; i++ {
+		tree.Insert(i, i)
+	}
+	snap := tree.Clone()
+	want := contents(snap)
+
+	// A single change should copy only a path, not the whole tree.
+	tree.Replace(500, -500)
+	before := nodeSet(snap.root, make(map[*node[int, int]]bool))
+	after := nodeSet(tree.root, make(map[*node[int, int]]bool))
+	var fresh int
+	for n := range after {
+		if !before[n] {
+			fresh++
+		}
+	}
+	if h := tree.root.height(); fresh > h {
+		t.Errorf("Replace copied %d nodes, want at most %d", fresh, h)
+	}
+
+	for i := 0; i < 1000; i += 3 {
+		tree.Remove(i)
+	}
+	for i := 1000; i < 1500; i++ {
+		tree.Insert(i, i)
+	}
+	checkTree(t, tree)
+	checkTree(t, snap)
+	if diff := cmp.Diff(want, contents(snap)); diff != "" {
+		t.Errorf("Snapshot changed (-want, +got)\n%s", diff)
+	}
+	if v, _ := tree.Lookup(500); v != -500 {
+		t.Errorf("Lookup(500): got %d, want -500", v)
+	}
+}
+
+func TestCloneSplitJoin(t *testing.T) {
+	tree := intTree(100, false, intRange(0, 500, 1))
+	snap := tree.Clone()
+	want := contents(snap)
+
+	lo, hi := tree.Split(250)
+	lo.SetRanked(true)
+	lo.Remove(10)
+	hi.Insert(1000, 0)
+	joined := Join(lo, hi)
+	joined.Replace(300, -1)
+	checkTree(t, joined)
+
+	checkTree(t, snap)
+	if diff := cmp.Diff(want, contents(snap)); diff != "" {
+		t.Errorf("Snapshot changed (-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,161 @@
+package generic
+
+// A Cursor is a position in the inorder sequence of a tree. Unlike the
+// callback-based traversal methods, a cursor can be advanced one step at a
+// time in either direction, interleaved with other work.
+//
+// A cursor is invalidated by any change to the structure of its tree, such as
+// inserting a new key or removing a key. Once a cursor is invalid, its Valid
+// method reports false and it cannot be moved; use the tree's Seek method to
+// obtain a new cursor. Replacing the value of an existing key does not
+// invalidate a cursor.
+type Cursor[K, V any] struct {
+	tree   *Tree[K, V]
+	path   []step[K, V] // from the root; the current node is last
+	mods   int          // modification count of tree at creation
+	copies int          // copy count of tree when path was last resolved
+}
+
+// First returns a cursor positioned at the minimum key of t. If t is empty,
+// the cursor is not valid.
+func (t *Tree[K, V]) First() *Cursor[K, V] {
+	c := t.newCursor()
+	for cur := t.root; cur != nil; cur = cur.left {
+		c.path = append(c.path, step[K, V]{cur, true})
+	}
+	return c
+}
+
+// Last returns a cursor positioned at the maximum key of t. If t is empty, the
+// cursor is not valid.
+func (t *Tree[K, V]) Last() *Cursor[K, V] {
+	c := t.newCursor()
+	for cur := t.root; cur != nil; cur = cur.right {
+		c.path = append(c.path, step[K, V]{cur, false})
+	}
+	return c
+}
+
+// Seek returns a cursor positioned at the smallest key in t greater than or
+// equal to key. If there is no such key, the cursor is not valid. If t is a
+// multimap, the cursor is positioned at the first of any equal keys.
+func (t *Tree[K, V]) Seek(key K) *Cursor[K, V] {
+	c := t.newCursor()
+	path := t.root.pathTo(key, t.compare, t.tie(-1))
+	for i, n := range path {
+		c.path = append(c.path, step[K, V]{n, i+1 < len(path) && path[i+1] == n.left})
+	}
+	if n := len(path); n != 0 && t.compare(path[n-1].key, key) < 0 {
+		// The path ended at a node with no right child, so the target is the
+		// successor of that node (if any).
+		c.Next()
+	}
+	return c
+}
+
+func (t *Tree[K, V]) newCursor() *Cursor[K, V] {
+	return &Cursor[K, V]{tree: t, mods: t.mods, copies: t.copies}
+}
+
+// Valid reports whether c is positioned at a key of its tree. A cursor is not
+// valid if it has moved past either end of the tree, or if the tree has been
+// modified since the cursor was created.
+func (c *Cursor[K, V]) Valid() bool { return len(c.path) != 0 && c.mods == c.tree.mods }
+
+// current returns the node at the current position of c, which must be valid.
+//
+// Replacing a value in a tree that shares nodes with a clone copies the nodes
+// on the path to that value rather than modifying them, so the path of c may
+// refer to nodes the tree no longer uses. The shape of the tree is unchanged,
+// however, so in that case c follows the same turns from the root again.
+func (c *Cursor[K, V]) current() *node[K, V] {
+	if c.copies != c.tree.copies {
+		for i := range c.path {
+			switch {
+			case i == 0:
+				c.path[i].n = c.tree.root
+			case c.path[i-1].left:
+				c.path[i].n = c.path[i-1].n.left
+			default:
+				c.path[i].n = c.path[i-1].n.right
+			}
+		}
+		c.copies = c.tree.copies
+	}
+	return c.path[len(c.path)-1].n
+}
+
+// Key returns the key at the current position of c, or a zero key if c is not
+// valid.
+func (c *Cursor[K, V]) Key() K {
+	if !c.Valid() {
+		var zero K
+		return zero
+	}
+	return c.current().key
+}
+
+// Value returns the value at the current position of c, or a zero value if c
+// is not valid.
+func (c *Cursor[K, V]) Value() V {
+	if !c.Valid() {
+		var zero V
+		return zero
+	}
+	return c.current().value
+}
+
+// Next advances c to the next key in order, and reports whether c is valid
+// after doing so. If c is not valid, Next does nothing and returns false.
+func (c *Cursor[K, V]) Next() bool {
+	if !c.Valid() {
+		return false
+	}
+	cur := c.current()
+	if cur.right != nil {
+		// The successor is the leftmost node of the right subtree.
+		c.path[len(c.path)-1].left = false
+		for cur = cur.right; cur != nil; cur = cur.left {
+			c.path = append(c.path, step[K, V]{cur, true})
+		}
+		return true
+	}
+
+	// The successor is the nearest ancestor whose left subtree we are leaving.
+	for {
+		c.path = c.path[:len(c.path)-1]
+		if len(c.path) == 0 {
+			return false
+		} else if c.path[len(c.path)-1].left {
+			return true
+		}
+	}
+}
+
+// Prev moves c to the previous key in order, and reports whether c is valid
+// after doing so. If c is not valid, Prev does nothing and returns false.
+func (c *Cursor[K, V]) Prev() bool {
+	if !c.Valid() {
+		return false
+	}
+	cur := c.current()
+	if cur.left != nil {
+		// The predecessor is the rightmost node of the left subtree.
+		c.path[len(c.path)-1].left = true
+		for cur = cur.left; cur != nil; cur = cur.right {
+			c.path = append(c.path, step[K, V]{cur, false})
+		}
+		return true
+	}
+
+	// The predecessor is the nearest ancestor whose right subtree we are
+	// leaving.
+	for {
+		c.path = c.path[:len(c.path)-1]
+		if len(c.path) == 0 {
+			return false
+		} else if !c.path[len(c.path)-1].left {
+			return true
+		}
+	}
+}
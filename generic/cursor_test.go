@@ -0,0 +1,189 @@
+package generic
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestCursor(t *testing.T) {
+	tree := New[int, string](0)
+	for _, k := range []int{8, 6, 7, 5, 3, 0, 9} {
+		tree.Insert(k, "")
+	}
+	all := []int{0, 3, 5, 6, 7, 8, 9}
+
+	var fwd []int
+	for c := tree.First(); c.Valid(); c.Next() {
+		fwd = append(fwd, c.Key())
+	}
+	if diff := cmp.Diff(all, fwd); diff != "" {
+		t.Errorf("Forward traversal (-want, +got)\n%s", diff)
+	}
+
+	var rev []int
+	for c := tree.Last(); c.Valid(); c.Prev() {
+		rev = append([]int{c.Key()}, rev...)
+	}
+	if diff := cmp.Diff(all, rev); diff != "" {
+		t.Errorf("Reverse traversal (-want, +got)\n%s", diff)
+	}
+
+	tests := []struct {
+		key  int
+		want []int
+	}{
+		{-1, all},
+		{0, all},
+		{1, all[1:]},
+		{4, all[2:]},
+		{7, all[4:]},
+		{9, all[6:]},
+		{10, nil},
+	}
+	for _, test := range tests {
+		var got []int
+		for c := tree.Seek(test.key); c.Valid(); c.Next() {
+			got = append(got, c.Key())
+		}
+		if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("Seek(%d) traversal (-want, +got)\n%s", test.key, diff)
+		}
+	}
+
+	// Moving back and forth should retrace the same keys.
+	c := tree.Seek(5)
+	for _, want := range []int{6, 7, 6, 5, 3, 0} {
+		if want > c.Key() {
+			c.Next()
+		} else {
+			c.Prev()
+		}
+		if got := c.Key(); got != want {
+			t.Errorf("Cursor key: got %d, want %d", got, want)
+		}
+	}
+	if c.Prev() || c.Valid() {
+		t.Error("Cursor is still valid after moving before the first key")
+	}
+	if c.Next() {
+		t.Error("Next succeeded on an exhausted cursor")
+	}
+}
+
+func TestCursorEmpty(t *testing.T) {
+	tree := New[string, int](100)
+	for _, c := range []*Cursor[string, int]{tree.First(), tree.Last(), tree.Seek("x")} {
+		if c.Valid() {
+			t.Errorf("Cursor on empty tree is valid at %q", c.Key())
+		}
+		if c.Key() != "" || c.Value() != 0 {
+			t.Errorf("Invalid cursor: got (%q, %d), want zeroes", c.Key(), c.Value())
+		}
+	}
+}
+
+func TestCursorInvalidation(t *testing.T) {
+	tree := New[string, int](100,
+		KV[string, int]{Key: "apple", Value: 1},
+		KV[string, int]{Key: "cherry", Value: 2},
+	)
+	c := tree.First()
+
+	// Replacing a value does not disturb the cursor, and it sees the update.
+	tree.Replace("apple", 10)
+	if !c.Valid() || c.Value() != 10 {
+		t.Errorf("After Replace: valid=%v value=%d, want true, 10", c.Valid(), c.Value())
+	}
+
+	// Likewise when the tree shares its nodes with a clone, so that replacing
+	// a value copies the nodes on its path.
+	snap := tree.Clone()
+	tree.Replace("apple", 20)
+	if !c.Valid() || c.Value() != 20 {
+		t.Errorf("After Clone and Replace: valid=%v value=%d, want true, 20", c.Valid(), c.Value())
+	}
+	if v, _ := snap.Lookup("apple"); v != 10 {
+		t.Errorf("Clone: apple = %d, want 10", v)
+	}
+	if !c.Next() || c.Key() != "cherry" {
+		t.Errorf("After Clone and Replace: Next: key=%q, want cherry", c.Key())
+	}
+	tree.Clone()
+	tree.Swap("cherry", 6)
+	tree.Clone()
+	tree.Upsert("cherry", func(v int, _ bool) int { return v + 1 })
+	if !c.Valid() || c.Value() != 7 {
+		t.Errorf("After Swap and Upsert: valid=%v value=%d, want true, 7", c.Valid(), c.Value())
+	}
+	c.Prev()
+
+	// Inserting an existing key is not a modification.
+	tree.Insert("cherry", 5)
+	if !c.Valid() {
+		t.Error("Cursor invalidated by a no-op Insert")
+	}
+
+	tree.Insert("banana", 3)
+	if c.Valid() || c.Next() {
+		t.Error("Cursor is still valid after Insert")
+	}
+
+	c = tree.Seek("banana")
+	if !c.Valid() || c.Key() != "banana" {
+		t.Fatalf("Seek(banana): valid=%v key=%q", c.Valid(), c.Key())
+	}
+	tree.Remove("nonesuch")
+	if !c.Valid() {
+		t.Error("Cursor invalidated by a no-op Remove")
+	}
+	tree.Remove("apple")
+	if c.Valid() || c.Prev() {
+		t.Error("Cursor is still valid after Remove")
+	}
+}
+
+func TestCursorReplaceAncestors(t *testing.T) {
+	// Replacing a key after a clone copies its ancestors, so the first
+	// replacement leaves the root owned by the tree, and the second relinks it
+	// in place to a copy of the cursor's node.
+	tree := New[int, int](100, KV[int, int]{2, 2}, KV[int, int]{1, 1}, KV[int, int]{3, 3})
+	c := tree.First()
+	tree.Clone()
+	tree.Replace(3, 30)
+	tree.Replace(1, 10)
+	if !c.Valid() || c.Key() != 1 || c.Value() != 10 {
+		t.Errorf("After Replace: valid=%v key=%d value=%d, want true, 1, 10", c.Valid(), c.Key(), c.Value())
+	}
+
+	// Walk a larger tree while replacing random keys, including the ancestors
+	// of the cursor, sometimes after a clone.
+	rng := rand.New(rand.NewSource(39))
+	tree = intTree(100, false, intRange(0, 200, 1))
+	want := make(map[int]int)
+	for i := 0; i < 200; i++ {
+		want[i] = i
+	}
+	c = tree.First()
+	for i := 0; c.Valid(); i++ {
+		if got := c.Key(); got != i {
+			t.Fatalf("Step %d: key is %d", i, got)
+		}
+		if got := c.Value(); got != want[i] {
+			t.Fatalf("Step %d: value is %d, want %d", i, got, want[i])
+		}
+		for j := 0; j < 3; j++ {
+			if rng.Intn(4) == 0 {
+				tree.Clone()
+			}
+			key := rng.Intn(200)
+			want[key] = rng.Int()
+			tree.Replace(key, want[key])
+		}
+		if !c.Next() && i != 199 {
+			t.Fatalf("Step %d: Next ended early", i)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package generic
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// encodingVersion is the version of the binary encoding written by
+// MarshalBinary. It is stored as the first byte of the encoding.
+const encodingVersion = 1
+
+// encodedHeader is the first gob message of a binary encoding, and is followed
+// by Len messages of type KV[K, V] in order of key. Keys are strictly
+// increasing unless Multi is true.
+type encodedHeader struct {
+	Beta, Len int
+	Multi     bool
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The encoding records the balancing factor of t, whether it is a multimap,
+// and its key/value pairs in order. Keys and values are encoded with
+// encoding/gob, so their types must be acceptable to gob. In particular, if V
+// is an interface type, the concrete types stored in the tree must be
+// registered with gob.Register.
+func (t *Tree[K, V]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(encodingVersion)
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(encodedHeader{Beta: t.β, Len: t.size, Multi: t.multi}); err != nil {
+		return nil, err
+	}
+	var err error
+	t.root.inorder(func(kv KV[K, V]) bool {
+		err = enc.Encode(kv)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. It
+// replaces the contents, balancing factor, and multimap setting of t with
+// those decoded from data. Because the keys are stored in order, the tree is
+// built directly in balanced form without sorting.
+//
+// If t was constructed by New or NewFunc, it keeps its comparison function.
+// Otherwise, as for a zero-valued tree allocated by a decoder, t uses the
+// natural order of K, which must have an ordered underlying type. The
+// comparison function is not part of the encoding, so to decode keys in any
+// other order, construct t with NewFunc before decoding into it.
+// UnmarshalBinary reports an error if no comparison is available, or if the
+// keys in data are out of order.
+func (t *Tree[K, V]) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty tree encoding")
+	} else if v := data[0]; v != encodingVersion {
+		return fmt.Errorf("unsupported tree encoding version %d", v)
+	}
+	compare, err := t.decodeCompare()
+	if err != nil {
+		return err
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(data[1:]))
+	var hdr encodedHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return fmt.Errorf("decoding tree header: %w", err)
+	} else if hdr.Beta < 0 || hdr.Beta > maxBalance {
+		return fmt.Errorf("invalid balancing factor %d", hdr.Beta)
+	} else if hdr.Len < 0 {
+		return fmt.Errorf("invalid tree size %d", hdr.Len)
+	}
+	t.ensureEpoch()
+	var nodes []*node[K, V]
+	for i := 0; i < hdr.Len; i++ {
+		var kv KV[K, V]
+		if err := dec.Decode(&kv); err != nil {
+			return fmt.Errorf("decoding entry %d: %w", i, err)
+		} else if i > 0 && !inOrder(compare(nodes[i-1].key, kv.Key), hdr.Multi) {
+			return fmt.Errorf("entry %d: key %v is out of order", i, kv.Key)
+		}
+		nodes = append(nodes, t.newNode(kv.Key, kv.Value))
+	}
+
+	t.β = hdr.Beta
+	t.limit = limitFunc(hdr.Beta)
+	t.multi = hdr.Multi
+	t.load(compare, nodes)
+	return nil
+}
+
+// decodeCompare returns the comparison function to use when decoding into t.
+// This is the comparison function of t, if it has one, or else the natural
+// order of K.
+func (t *Tree[K, V]) decodeCompare() (func(a, b K) int, error) {
+	if t.compare != nil {
+		return t.compare, nil
+	} else if compare := defaultCompare[K](); compare != nil {
+		return compare, nil
+	}
+	var zero K
+	return nil, fmt.Errorf("no comparison function for key type %T", zero)
+}
+
+// ensureEpoch assigns an epoch to t if it does not already have one, as in a
+// zero-valued tree.
+func (t *Tree[K, V]) ensureEpoch() {
+	if t.epoch == 0 {
+		t.epoch = newEpoch()
+	}
+}
+
+// load replaces the contents of t with nodes, which must be owned by t and in
+// order by compare.
+func (t *Tree[K, V]) load(compare func(a, b K) int, nodes []*node[K, V]) {
+	t.compare = compare
+	if t.limit == nil {
+		t.limit = limitFunc(t.β)
+	}
+	t.root = t.extract(nodes)
+	t.size, t.max = len(nodes), len(nodes)
+	t.pending, t.excess, t.loose = nil, 0, 0
+	t.mods++
+}
+
+// GobEncode implements the gob.GobEncoder interface, using the same encoding
+// as MarshalBinary.
+func (t *Tree[K, V]) GobEncode() ([]byte, error) { return t.MarshalBinary() }
+
+// GobDecode implements the gob.GobDecoder interface, using the same encoding
+// as UnmarshalBinary.
+func (t *Tree[K, V]) GobDecode(data []byte) error { return t.UnmarshalBinary(data) }
+
+// defaultCompare returns the comparison function for keys of type K to use in
+// a tree that does not have one, or nil if K is not ordered.
+func defaultCompare[K any]() func(a, b K) int {
+	var f any
+	switch any(*new(K)).(type) {
+	case string:
+		f = cmp.Compare[string]
+	case int:
+		f = cmp.Compare[int]
+	case int8:
+		f = cmp.Compare[int8]
+	case int16:
+		f = cmp.Compare[int16]
+	case int32:
+		f = cmp.Compare[int32]
+	case int64:
+		f = cmp.Compare[int64]
+	case uint:
+		f = cmp.Compare[uint]
+	case uint8:
+		f = cmp.Compare[uint8]
+	case uint16:
+		f = cmp.Compare[uint16]
+	case uint32:
+		f = cmp.Compare[uint32]
+	case uint64:
+		f = cmp.Compare[uint64]
+	case uintptr:
+		f = cmp.Compare[uintptr]
+	case float32:
+		f = cmp.Compare[float32]
+	case float64:
+		f = cmp.Compare[float64]
+	default:
+		return kindCompare[K]()
+	}
+	return f.(func(a, b K) int)
+}
+
+// kindCompare returns a comparison function for keys of type K based on the
+// natural order of its underlying kind, or nil if the kind is not ordered.
+// This handles defined types such as "type Name string".
+func kindCompare[K any]() func(a, b K) int {
+	switch reflect.TypeOf((*K)(nil)).Elem().Kind() {
+	case reflect.String:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).String(), reflect.ValueOf(b).String())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).Int(), reflect.ValueOf(b).Int())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).Uint(), reflect.ValueOf(b).Uint())
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).Float(), reflect.ValueOf(b).Float())
+		}
+	}
+	return nil
+}
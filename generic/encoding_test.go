@@ -0,0 +1,143 @@
+package generic
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Tree[string, int])(nil)
+	_ encoding.BinaryUnmarshaler = (*Tree[string, int])(nil)
+	_ gob.GobEncoder             = (*Tree[string, int])(nil)
+	_ gob.GobDecoder             = (*Tree[string, int])(nil)
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	tree, _ := makeTree(150, `now is the time for all good men to come to the aid of the party`)
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	// Decoding into a zero tree uses the natural order of the keys.
+	var zero Tree[string, int]
+	if err := zero.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary (zero) failed: %v", err)
+	}
+	checkTree(t, &zero)
+	if diff := cmp.Diff(contents(tree), contents(&zero)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+	if zero.β != 150 {
+		t.Errorf("Decoded β: got %d, want 150", zero.β)
+	}
+	zero.Insert("zebra", 0)
+	zero.Remove("now")
+	checkTree(t, &zero)
+
+	// Decoding replaces the existing contents of a constructed tree.
+	other := New[string, int](0, KV[string, int]{Key: "xyzzy"})
+	other.SetRanked(true)
+	if err := other.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary (other) failed: %v", err)
+	}
+	checkTree(t, other)
+	if diff := cmp.Diff(contents(tree), contents(other)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+}
+
+func TestBinaryEmpty(t *testing.T) {
+	data, err := New[int, int](50).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	tree := New[int, int](0, KV[int, int]{Key: 1})
+	if err := tree.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if tree.Len() != 0 || tree.root != nil {
+		t.Errorf("Decoded tree has %d elements, want 0", tree.Len())
+	}
+}
+
+type revKey struct{ N int }
+
+func compareRevKey(a, b revKey) int { return b.N - a.N }
+
+func TestBinaryCustomOrder(t *testing.T) {
+	tree := NewFunc[revKey, string](100, compareRevKey)
+	for i := 0; i < 20; i++ {
+		tree.Insert(revKey{i}, strings.Repeat("*", i))
+	}
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	// A constructed tree keeps its own comparison.
+	dup := NewFunc[revKey, string](0, compareRevKey)
+	if err := dup.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if diff := cmp.Diff(contents(tree), contents(dup)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+
+	// A zero tree has no comparison for a non-ordered key type.
+	var zero Tree[revKey, string]
+	if err := zero.UnmarshalBinary(data); err == nil {
+		t.Error("UnmarshalBinary without a comparison succeeded unexpectedly")
+	}
+
+	// Data encoded in one order cannot be decoded in another.
+	fwd := NewFunc[revKey, string](0, func(a, b revKey) int { return a.N - b.N })
+	if err := fwd.UnmarshalBinary(data); err == nil {
+		t.Error("UnmarshalBinary with the wrong order succeeded unexpectedly")
+	}
+	if fwd.Len() != 0 {
+		t.Errorf("Failed decoding modified the tree: Len = %d", fwd.Len())
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	tree := New[int, bool](0)
+	for _, bad := range [][]byte{nil, {0}, {99, 1, 2, 3}, {encodingVersion}, {encodingVersion, 0xff}} {
+		if err := tree.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary(%q) succeeded unexpectedly", bad)
+		}
+	}
+}
+
+func TestGob(t *testing.T) {
+	type record struct {
+		Name  string
+		Index *Tree[int, []string]
+	}
+	in := record{Name: "test", Index: New[int, []string](200)}
+	for i := 0; i < 50; i++ {
+		in.Index.Insert(i*7%50, []string{strings.Repeat("x", i%4)})
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var out record
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if out.Name != in.Name {
+		t.Errorf("Name: got %q, want %q", out.Name, in.Name)
+	}
+	checkTree(t, out.Index)
+	if diff := cmp.Diff(contents(in.Index), contents(out.Index), cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Decoded index (-want, +got)\n%s", diff)
+	}
+}
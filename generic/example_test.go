@@ -0,0 +1,32 @@
+package generic_test
+
+import (
+	"fmt"
+
+	"github.com/creachadair/scapegoat/generic"
+)
+
+func ExampleTree_Seek() {
+	// Merge the keys of two trees in order.
+	odd := generic.New(100,
+		generic.KV[int, string]{Key: 1}, generic.KV[int, string]{Key: 5},
+		generic.KV[int, string]{Key: 7},
+	)
+	even := generic.New(100,
+		generic.KV[int, string]{Key: 2}, generic.KV[int, string]{Key: 4},
+		generic.KV[int, string]{Key: 8},
+	)
+	a, b := odd.Seek(2), even.First()
+	for a.Valid() || b.Valid() {
+		if !b.Valid() || (a.Valid() && a.Key() < b.Key()) {
+			fmt.Print(a.Key(), " ")
+			a.Next()
+		} else {
+			fmt.Print(b.Key(), " ")
+			b.Next()
+		}
+	}
+	fmt.Println()
+	// Output:
+	// 2 4 5 7 8
+}
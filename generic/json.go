@@ -0,0 +1,127 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// If the kind of K is string, the tree is encoded as a JSON object whose
+// members are the keys of t in order. Otherwise, the tree is encoded as a
+// JSON array of {"key": k, "value": v} objects in order of key. Keys and
+// values are encoded with encoding/json.
+func (t *Tree[K, V]) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	stringKeys := isStringKind[K]()
+	if stringKeys {
+		buf.WriteByte('{')
+	} else {
+		buf.WriteByte('[')
+	}
+	var err error
+	first := true
+	t.root.inorder(func(kv KV[K, V]) bool {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		var data []byte
+		if stringKeys {
+			data, err = json.Marshal(reflect.ValueOf(kv.Key).String())
+			if err != nil {
+				return false
+			}
+			buf.Write(data)
+			buf.WriteByte(':')
+			data, err = json.Marshal(kv.Value)
+		} else {
+			data, err = json.Marshal(kv)
+		}
+		buf.Write(data)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stringKeys {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It replaces the
+// contents of t with the key/value pairs decoded from data, which may be in
+// either of the forms written by MarshalJSON, although the object form is
+// only accepted if the kind of K is string. A JSON null leaves t unchanged.
+//
+// The keys need not be in order. If a key occurs more than once, the last
+// value for that key is kept, unless t is a multimap, in which case all the
+// values are kept in the order they occur. Either way, the result is built
+// directly in balanced form. The balancing factor of t is not changed.
+//
+// Like UnmarshalBinary, UnmarshalJSON uses the comparison function of t if it
+// has one, and otherwise the natural order of K.
+func (t *Tree[K, V]) UnmarshalJSON(data []byte) error {
+	compare, err := t.decodeCompare()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	} else if tok == nil {
+		return nil // null
+	}
+
+	t.ensureEpoch()
+	var nodes []*node[K, V]
+	switch tok {
+	case json.Delim('{'):
+		if !isStringKind[K]() {
+			var zero K
+			return fmt.Errorf("cannot decode a JSON object into a tree with key type %T", zero)
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			var key K
+			reflect.ValueOf(&key).Elem().SetString(tok.(string))
+			var value V
+			if err := dec.Decode(&value); err != nil {
+				return fmt.Errorf("decoding value for key %q: %w", tok, err)
+			}
+			nodes = append(nodes, t.newNode(key, value))
+		}
+	case json.Delim('['):
+		for dec.More() {
+			var kv KV[K, V]
+			if err := dec.Decode(&kv); err != nil {
+				return fmt.Errorf("decoding entry %d: %w", len(nodes), err)
+			}
+			nodes = append(nodes, t.newNode(kv.Key, kv.Value))
+		}
+	default:
+		return errors.New("tree must be encoded as a JSON object or array")
+	}
+	if _, err := dec.Token(); err != nil { // closing delimiter
+		return err
+	}
+
+	t.load(compare, sortUnique(nodes, compare, t.multi))
+	return nil
+}
+
+// isStringKind reports whether the kind of K is string.
+func isStringKind[K any]() bool {
+	return reflect.TypeOf((*K)(nil)).Elem().Kind() == reflect.String
+}
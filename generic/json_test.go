@@ -0,0 +1,144 @@
+package generic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	_ json.Marshaler   = (*Tree[string, int])(nil)
+	_ json.Unmarshaler = (*Tree[string, int])(nil)
+)
+
+type name string
+
+func TestJSONObject(t *testing.T) {
+	tree := New[name, int](200,
+		KV[name, int]{Key: "plum", Value: 3},
+		KV[name, int]{Key: "apple", Value: 1},
+		KV[name, int]{Key: "cherry", Value: 2},
+	)
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	const want = `{"apple":1,"cherry":2,"plum":3}`
+	if got := string(data); got != want {
+		t.Errorf("Marshal: got %s, want %s", got, want)
+	}
+
+	var zero Tree[name, int]
+	if err := json.Unmarshal(data, &zero); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	checkTree(t, &zero)
+	if diff := cmp.Diff(contents(tree), contents(&zero)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+	zero.Insert("banana", 4)
+	checkTree(t, &zero)
+}
+
+func TestJSONArray(t *testing.T) {
+	tree := New[int, string](200,
+		KV[int, string]{Key: 5, Value: "five"},
+		KV[int, string]{Key: -2, Value: "minus two"},
+		KV[int, string]{Key: 0},
+	)
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	const want = `[{"key":-2,"value":"minus two"},{"key":0,"value":""},{"key":5,"value":"five"}]`
+	if got := string(data); got != want {
+		t.Errorf("Marshal: got %s, want %s", got, want)
+	}
+
+	dup := New[int, string](0, KV[int, string]{Key: 100})
+	if err := json.Unmarshal(data, dup); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	checkTree(t, dup)
+	if diff := cmp.Diff(contents(tree), contents(dup)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+
+	empty, err := json.Marshal(New[int, string](0))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	} else if got := string(empty); got != "[]" {
+		t.Errorf("Marshal empty: got %s, want []", got)
+	}
+}
+
+func TestJSONUnordered(t *testing.T) {
+	const input = `{"c":1, "a":2, "b":3, "a":4, "d":5, "c":6}`
+	tree := New[string, int](100)
+	if err := json.Unmarshal([]byte(input), tree); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	checkTree(t, tree)
+	want := []KV[string, int]{{"a", 4}, {"b", 3}, {"c", 6}, {"d", 5}}
+	if diff := cmp.Diff(want, contents(tree)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+
+	// The array form is accepted for string keys too.
+	if err := json.Unmarshal([]byte(`[{"key":"z","value":1},{"key":"y"}]`), tree); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want = []KV[string, int]{{"y", 0}, {"z", 1}}
+	if diff := cmp.Diff(want, contents(tree)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+
+	// A null leaves the tree alone.
+	if err := json.Unmarshal([]byte(`null`), tree); err != nil {
+		t.Fatalf("Unmarshal null failed: %v", err)
+	} else if tree.Len() != 2 {
+		t.Errorf("After null: Len = %d, want 2", tree.Len())
+	}
+}
+
+func TestJSONInStruct(t *testing.T) {
+	type config struct {
+		Hosts *Tree[string, []int] `json:"hosts"`
+		Ports *Tree[int, bool]     `json:"ports"`
+	}
+	const input = `{"hosts":{"b":[2],"a":[1,1]},"ports":[{"key":443,"value":true},{"key":80}]}`
+	var cfg config
+	if err := json.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	const want = `{"hosts":{"a":[1,1],"b":[2]},"ports":[{"key":80,"value":false},{"key":443,"value":true}]}`
+	if got := string(data); got != want {
+		t.Errorf("Round trip: got %s, want %s", got, want)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []string{
+		`{"a":1}`,                   // object with non-string keys
+		`"x"`,                       // neither object nor array
+		`[{"key":"one","value":1}]`, // wrong key type
+		`[1, 2]`,                    // wrong entry type
+	}
+	for _, input := range tests {
+		tree := New[int, int](0)
+		if err := json.Unmarshal([]byte(input), tree); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded unexpectedly", input)
+		}
+	}
+
+	type point struct{ X, Y int }
+	var zero Tree[point, int]
+	if err := json.Unmarshal([]byte(`[]`), &zero); err == nil {
+		t.Error("Unmarshal without a comparison succeeded unexpectedly")
+	}
+}
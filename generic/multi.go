@@ -0,0 +1,94 @@
+package generic
+
+// SetMulti enables or disables multimap mode for t.
+//
+// When t is a multimap, Insert always adds a new node, even if the key is
+// already present, so that t may contain several nodes with equal keys. Nodes
+// with equal keys are kept in the order they were inserted: The traversal
+// methods visit them in that order, and Lookup, Replace, and Remove act on the
+// first of them. Use LookupAll to visit all the values for a key, and
+// RemoveAll to remove all of them.
+//
+// The set operations treat a multimap as a multiset of keys, pairing the
+// nodes with equal keys in order; for example, the union of trees in which a
+// key occurs m and n times contains max(m, n) occurrences of that key.
+//
+// SetMulti panics if multi is false and t contains duplicate keys.
+func (t *Tree[K, V]) SetMulti(multi bool) {
+	if !multi && t.multi {
+		var prev *K
+		t.root.inorder(func(kv KV[K, V]) bool {
+			if prev != nil && t.compare(*prev, kv.Key) == 0 {
+				panic("tree contains duplicate keys")
+			}
+			prev = &kv.Key
+			return true
+		})
+	}
+	if multi != t.multi {
+		t.completeRebuild()
+	}
+	t.multi = multi
+}
+
+// LookupAll calls f with each value associated with key in t, in order, until
+// f returns false or no further values are available. Unless t is a
+// multimap, f is called at most once.
+func (t *Tree[K, V]) LookupAll(key K, f func(V) bool) {
+	t.root.inorderAfter(key, t.compare, t.tie(-1), func(kv KV[K, V]) bool {
+		return t.compare(kv.Key, key) == 0 && f(kv.Value)
+	})
+}
+
+// tie returns dir if t is a multimap, and otherwise 0. The result is suitable
+// as the tie argument to pathTo.
+func (t *Tree[K, V]) tie(dir int) int {
+	if t.multi {
+		return dir
+	}
+	return 0
+}
+
+// replaceFirst updates the value of the first node with key in the subtree
+// under n, returning the modified tree and reporting whether key was found.
+func (t *Tree[K, V]) replaceFirst(n *node[K, V], key K, value V) (_ *node[K, V], ok bool) {
+	var buf [pathBuf]step[K, V]
+	path, target := t.pathToFirst(buf[:0], n, key)
+	if target == nil {
+		return n, false
+	}
+	target = t.mut(target)
+	target.value = value
+	t.fix(target)
+	return t.relink(path, target), true
+}
+
+// pathToFirst finds the first node with key in the subtree under n, and
+// returns the path to that node, not including the node itself, appended to
+// path, and the node. If key is not found, the node is nil, and the path
+// leads to where key would be inserted.
+//
+// If t is a multimap, the search continues to the left past each node with
+// key, and the first node is the last of these on the path.
+func (t *Tree[K, V]) pathToFirst(path []step[K, V], n *node[K, V], key K) (_ []step[K, V], first *node[K, V]) {
+	eq := -1 // index of the last node with key on the path, if any
+	for cur := n; cur != nil; {
+		c := t.compare(key, cur.key)
+		if c == 0 {
+			if !t.multi {
+				return path, cur
+			}
+			eq = len(path)
+		}
+		path = append(path, step[K, V]{cur, c <= 0})
+		if c <= 0 {
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+	if eq < 0 {
+		return path, nil
+	}
+	return path[:eq], path[eq].n
+}
@@ -0,0 +1,277 @@
+package generic
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// multiModel is a reference implementation of a multimap, as a slice of
+// key/value pairs ordered by key and then by insertion.
+type multiModel []KV[int, int]
+
+// lower returns the index of the first entry with a key ≥ key.
+func (m multiModel) lower(key int) int {
+	return sort.Search(len(m), func(i int) bool { return m[i].Key >= key })
+}
+
+// upper returns the index of the first entry with a key > key.
+func (m multiModel) upper(key int) int {
+	return sort.Search(len(m), func(i int) bool { return m[i].Key > key })
+}
+
+func (m *multiModel) insert(key, value int) {
+	i := m.upper(key)
+	*m = append(*m, KV[int, int]{})
+	copy((*m)[i+1:], (*m)[i:])
+	(*m)[i] = KV[int, int]{Key: key, Value: value}
+}
+
+func (m *multiModel) remove(key int) bool {
+	i := m.lower(key)
+	if i == len(*m) || (*m)[i].Key != key {
+		return false
+	}
+	*m = append((*m)[:i], (*m)[i+1:]...)
+	return true
+}
+
+func (m multiModel) values(key int) []int {
+	var out []int
+	for _, kv := range m[m.lower(key):m.upper(key)] {
+		out = append(out, kv.Value)
+	}
+	return out
+}
+
+func treeValues(tree *Tree[int, int], key int) []int {
+	var out []int
+	tree.LookupAll(key, func(v int) bool {
+		out = append(out, v)
+		return true
+	})
+	return out
+}
+
+func TestMulti(t *testing.T) {
+	for _, β := range []int{0, 300, 1000} {
+		for _, ranked := range []bool{false, true} {
+			tree := New[int, int](β)
+			tree.SetRanked(ranked)
+			tree.SetMulti(true)
+			var model multiModel
+
+			rng := rand.New(rand.NewSource(int64(β)))
+			for i := 0; i < 2000; i++ {
+				key := rng.Intn(20)
+				switch op := rng.Intn(10); {
+				case op < 5:
+					if !tree.Insert(key, i) {
+						t.Errorf("Insert(%d, %d) reported false", key, i)
+					}
+					model.insert(key, i)
+				case op < 8:
+					if got, want := tree.Remove(key), model.remove(key); got != want {
+						t.Errorf("Remove(%d): got %v, want %v", key, got, want)
+					}
+				case op < 9:
+					vs := model.values(key)
+					if got, want := tree.Replace(key, -i), len(vs) == 0; got != want {
+						t.Errorf("Replace(%d): got %v, want %v", key, got, want)
+					}
+					if len(vs) == 0 {
+						model.insert(key, -i)
+					} else {
+						model[model.lower(key)].Value = -i
+					}
+				default:
+					want := len(model.values(key))
+					for model.remove(key) {
+					}
+					if got := tree.RemoveAll(key); got != want {
+						t.Errorf("RemoveAll(%d): got %d, want %d", key, got, want)
+					}
+				}
+			}
+			checkTree(t, tree)
+			if diff := cmp.Diff([]KV[int, int](model), contents(tree)); diff != "" {
+				t.Fatalf("β=%d ranked=%v: contents (-want, +got)\n%s", β, ranked, diff)
+			}
+
+			for key := -1; key <= 20; key++ {
+				vs := model.values(key)
+				if diff := cmp.Diff(vs, treeValues(tree, key)); diff != "" {
+					t.Errorf("LookupAll(%d) (-want, +got)\n%s", key, diff)
+				}
+				if v, ok := tree.Lookup(key); ok != (len(vs) != 0) || (ok && v != vs[0]) {
+					t.Errorf("Lookup(%d): got (%d, %v), want first of %v", key, v, ok, vs)
+				}
+				if got, want := tree.Rank(key), model.lower(key); got != want {
+					t.Errorf("Rank(%d): got %d, want %d", key, got, want)
+				}
+				if i := model.lower(key); i < len(model) {
+					if got, ok := tree.Ceiling(key); !ok || got != model[i] {
+						t.Errorf("Ceiling(%d): got %v, want %v", key, got, model[i])
+					}
+					if c := tree.Seek(key); c.Key() != model[i].Key || c.Value() != model[i].Value {
+						t.Errorf("Seek(%d): got %d=%d, want %v", key, c.Key(), c.Value(), model[i])
+					}
+					var got []KV[int, int]
+					tree.InorderAfter(key, func(kv KV[int, int]) bool {
+						got = append(got, kv)
+						return true
+					})
+					if diff := cmp.Diff([]KV[int, int](model[i:]), got); diff != "" {
+						t.Errorf("InorderAfter(%d) (-want, +got)\n%s", key, diff)
+					}
+				}
+				if i := model.upper(key); i > 0 {
+					if got, ok := tree.Floor(key); !ok || got != model[i-1] {
+						t.Errorf("Floor(%d): got %v, want %v", key, got, model[i-1])
+					}
+					var got []KV[int, int]
+					tree.InorderBefore(key, func(kv KV[int, int]) bool {
+						got = append(got, kv)
+						return true
+					})
+					if len(got) != i || got[0] != model[i-1] || got[i-1] != model[0] {
+						t.Errorf("InorderBefore(%d): got %v, want reverse of %v", key, got, model[:i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMultiSplitJoin(t *testing.T) {
+	tree := New[int, int](200)
+	tree.SetMulti(true)
+	for i := 0; i < 60; i++ {
+		tree.Insert(i%6, i)
+	}
+	want := contents(tree)
+
+	left, right := tree.Split(3)
+	checkTree(t, left)
+	checkTree(t, right)
+	if got := left.Len(); got != 30 {
+		t.Errorf("Split: left has %d elements, want 30", got)
+	}
+	if kv := right.Min(); kv.Key != 3 || kv.Value != 3 {
+		t.Errorf("Split: right.Min() = %v, want 3=3", kv)
+	}
+
+	// Split the right part again, at a key present in both parts, and join
+	// the pieces back together in the other order.
+	a, b := right.Split(4)
+	c, d := left.Split(2)
+	ab := Join(b, a)
+	cd := Join(d, c)
+	joined := Join(ab, cd)
+	checkTree(t, joined)
+	if diff := cmp.Diff(want, contents(joined)); diff != "" {
+		t.Errorf("Joined tree (-want, +got)\n%s", diff)
+	}
+
+	// Trees whose only keys are equal can be joined either way around.
+	x, y := New[int, int](0), New[int, int](0)
+	x.SetMulti(true)
+	x.Insert(1, 10)
+	y.Insert(1, 20)
+	y.Insert(2, 30)
+	xy := Join(y, x)
+	if diff := cmp.Diff([]KV[int, int]{{1, 10}, {1, 20}, {2, 30}}, contents(xy)); diff != "" {
+		t.Errorf("Joined tree (-want, +got)\n%s", diff)
+	}
+}
+
+func TestMultiSetOps(t *testing.T) {
+	mk := func(keys ...int) *Tree[int, int] {
+		tree := New[int, int](0)
+		tree.SetMulti(true)
+		for i, key := range keys {
+			tree.Insert(key, i)
+		}
+		return tree
+	}
+	allKeys := func(tree *Tree[int, int]) []int {
+		var out []int
+		tree.Inorder(func(kv KV[int, int]) bool {
+			out = append(out, kv.Key)
+			return true
+		})
+		return out
+	}
+	a, b := mk(1, 1, 1, 2, 3, 3), mk(1, 2, 2, 3, 3, 3, 4)
+	tests := []struct {
+		name string
+		got  *Tree[int, int]
+		want []int
+	}{
+		{"Union", a.Union(b, nil), []int{1, 1, 1, 2, 2, 3, 3, 3, 4}},
+		{"Intersect", a.Intersect(b), []int{1, 2, 3, 3}},
+		{"Difference", a.Difference(b), []int{1, 1}},
+		{"SymmetricDifference", a.SymmetricDifference(b), []int{1, 1, 2, 3, 4}},
+	}
+	for _, test := range tests {
+		checkTree(t, test.got)
+		if diff := cmp.Diff(test.want, allKeys(test.got)); diff != "" {
+			t.Errorf("%s (-want, +got)\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestMultiEncoding(t *testing.T) {
+	tree := New[string, int](100)
+	tree.SetMulti(true)
+	for i, key := range []string{"b", "a", "b", "c", "a", "b"} {
+		tree.Insert(key, i)
+	}
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var dup Tree[string, int]
+	if err := dup.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if !dup.multi {
+		t.Error("Decoded tree is not a multimap")
+	}
+	if diff := cmp.Diff(contents(tree), contents(&dup)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+
+	// JSON does not record the mode, but a multimap keeps duplicate keys.
+	js := New[string, int](100)
+	js.SetMulti(true)
+	if err := js.UnmarshalJSON([]byte(`{"b":1, "a":2, "b":3}`)); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	want := []KV[string, int]{{"a", 2}, {"b", 1}, {"b", 3}}
+	if diff := cmp.Diff(want, contents(js)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+}
+
+func TestSetMulti(t *testing.T) {
+	tree := New[int, int](0)
+	tree.SetMulti(true)
+	tree.Insert(1, 1)
+	tree.Insert(2, 2)
+	tree.SetMulti(false) // OK, no duplicates
+	if tree.Insert(1, 3) {
+		t.Error("Insert of a duplicate key succeeded after SetMulti(false)")
+	}
+
+	tree.SetMulti(true)
+	tree.Insert(1, 3)
+	defer func() {
+		if x := recover(); x == nil {
+			t.Error("SetMulti(false) with duplicates did not panic")
+		}
+	}()
+	tree.SetMulti(false)
+}
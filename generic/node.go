@@ -0,0 +1,443 @@
+package generic
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+type node[K, V any] struct {
+	key         K
+	value       V
+	left, right *node[K, V]
+
+	// The epoch of the tree that owns this node. Only the owner may modify a
+	// node in place; other trees sharing the node must copy it. See Tree.Clone.
+	// The low bits of a tree epoch are zero; here they record the layout of
+	// the node.
+	epoch uint64
+}
+
+// A layout identifies the type a node was allocated as, and thus the metadata
+// it has room for. Each layout has room for the metadata of those before it.
+type layout uint64
+
+const (
+	plainLayout  layout = iota // a node
+	rankedLayout               // a rankedNode
+	aggLayout                  // an aggNode
+
+	layoutBits = 2 // low bits of the epoch holding the layout
+	layoutMask = 1<<layoutBits - 1
+)
+
+func (l layout) String() string {
+	return [...]string{"plain", "ranked", "augmented"}[l]
+}
+
+// layout reports the layout of n.
+func (n *node[K, V]) layout() layout { return layout(n.epoch & layoutMask) }
+
+// owner reports the epoch of the tree that owns n.
+func (n *node[K, V]) owner() uint64 { return n.epoch &^ layoutMask }
+
+// A rankedNode is the layout of the nodes of a ranked tree, which also record
+// the number of nodes in their subtrees; see Tree.SetRanked. Other trees use
+// plain nodes, so that they do not pay for the count, except that augmented
+// trees use aggNodes, which include it.
+type rankedNode[K, V any] struct {
+	node[K, V]
+	count int
+}
+
+// ranked returns the rankedNode containing n. All the nodes of a ranked tree
+// have room for a count; ranked panics if n does not.
+func (n *node[K, V]) ranked() *rankedNode[K, V] {
+	if n.layout() < rankedLayout {
+		panic(fmt.Sprintf("node %v has %v layout, with no subtree count", n.key, n.layout()))
+	}
+	return (*rankedNode[K, V])(unsafe.Pointer(n))
+}
+
+// weight reports the cached subtree count of n, or 0 if n == nil. The node
+// must belong to a ranked tree.
+func (n *node[K, V]) weight() int {
+	if n == nil {
+		return 0
+	}
+	return n.ranked().count
+}
+
+// kv returns the key/value pair stored in n, and reports whether n != nil.
+func (n *node[K, V]) kv() (KV[K, V], bool) {
+	if n == nil {
+		return KV[K, V]{}, false
+	}
+	return KV[K, V]{Key: n.key, Value: n.value}, true
+}
+
+// Note: The traversals and updates in this package do not recurse along the
+// paths of a tree, but keep an explicit stack or path instead. Without
+// rebalancing (β = 1000), a tree built by ordered insertions degenerates into
+// a list, and recursion would then grow the goroutine stack in proportion to
+// the size of the tree. Only extract recurses, since the trees it builds are
+// balanced.
+
+// pathBuf is the length of the buffers the common operations use to record
+// paths and stacks without allocating. Longer paths spill onto the heap.
+const pathBuf = 64
+
+// size reports the number of nodes contained in the tree rooted at n.
+// If n == nil, this is defined as 0.
+func (n *node[K, V]) size() int {
+	var size int
+	n.walk(func(*node[K, V]) { size++ })
+	return size
+}
+
+// sizeUpTo returns the number of nodes in the subtree rooted at n, if that is
+// at most max, or otherwise some value greater than max. It visits no more
+// than max+1 nodes.
+func (n *node[K, V]) sizeUpTo(max int) int {
+	var size int
+	n.each(func(*node[K, V]) bool {
+		size++
+		return size <= max
+	})
+	return size
+}
+
+// height returns the number of nodes on the longest path from n to a leaf.
+func (n *node[K, V]) height() int {
+	type entry struct {
+		n     *node[K, V]
+		depth int
+	}
+	var h int
+	stack := []entry{{n, 0}}
+	for len(stack) != 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e.n != nil {
+			h = max(h, e.depth+1)
+			stack = append(stack, entry{e.n.left, e.depth + 1}, entry{e.n.right, e.depth + 1})
+		}
+	}
+	return h
+}
+
+// each visits the nodes of the subtree under n inorder, calling f for each
+// until f returns false, and reports whether all the nodes were visited.
+func (n *node[K, V]) each(f func(*node[K, V]) bool) bool {
+	var buf [pathBuf]*node[K, V]
+	stack := buf[:0]
+	for cur := n; cur != nil || len(stack) != 0; cur = cur.right {
+		for ; cur != nil; cur = cur.left {
+			stack = append(stack, cur)
+		}
+		cur = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !f(cur) {
+			return false
+		}
+	}
+	return true
+}
+
+// eachReverse visits the nodes of the subtree under n in reverse order, as
+// for each.
+func (n *node[K, V]) eachReverse(f func(*node[K, V]) bool) bool {
+	var buf [pathBuf]*node[K, V]
+	stack := buf[:0]
+	for cur := n; cur != nil || len(stack) != 0; cur = cur.left {
+		for ; cur != nil; cur = cur.right {
+			stack = append(stack, cur)
+		}
+		cur = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !f(cur) {
+			return false
+		}
+	}
+	return true
+}
+
+// flatten extracts the nodes rooted at n into a slice in order, and returns
+// the resulting slice. The results are appended to into, thus allowing the
+// caller to preallocate storage:
+//
+// Example:
+//
+//	into := n.flatten(make([]*node[K, V], 0, n.size()))
+//
+// If cap(into) ≥ n.size(), this method does not allocate on the heap, unless
+// the subtree is too deep for its traversal stack to fit in a pathBuf.
+func (n *node[K, V]) flatten(into []*node[K, V]) []*node[K, V] {
+	n.each(func(n *node[K, V]) bool {
+		into = append(into, n)
+		return true
+	})
+	return into
+}
+
+// extract constructs a balanced tree from the given nodes and returns the root
+// of the tree. The child pointers, subtree counts, and aggregates of the
+// resulting nodes are updated in place, so t must own the nodes. This
+// function does not allocate on the heap, apart from any allocation done to
+// compute aggregates.
+func (t *Tree[K, V]) extract(nodes []*node[K, V]) *node[K, V] {
+	if len(nodes) == 0 {
+		return nil
+	}
+	mid := (len(nodes) - 1) / 2
+	root := nodes[mid]
+	if t.ranked {
+		root.ranked().count = len(nodes)
+	}
+	root.left = t.extract(nodes[:mid])
+	root.right = t.extract(nodes[mid+1:])
+	if t.aug != nil {
+		t.aug.update(root)
+	}
+	return root
+}
+
+// rewrite composes flatten and extract, returning the rewritten root, which is
+// at the given depth in t. Any nodes of the subtree not owned by t are copied
+// before they are relinked. Costs a single size-element array allocation,
+// plus space for a traversal stack proportional to the height of the subtree,
+// but does no other allocation unless nodes must be copied.
+func (t *Tree[K, V]) rewrite(root *node[K, V], size, depth int) *node[K, V] {
+	start := t.rebuildStart()
+	nodes := root.flatten(make([]*node[K, V], 0, size))
+	if len(nodes) != size {
+		panic(fmt.Sprintf("len(nodes) = %d but size = %d", len(nodes), size))
+	}
+	for i, n := range nodes {
+		nodes[i] = t.mut(n)
+	}
+	root = t.extract(nodes)
+	t.rebuilt(size, depth, start)
+	return root
+}
+
+// popMinRight removes the smallest node from the right subtree of root,
+// returning the node removed. Nodes along the path that are not owned by t are
+// copied; the removed node itself is not modified. The caller must own root,
+// and is responsible for updating its count.
+// This function panics if root == nil or root.right == nil.
+func (t *Tree[K, V]) popMinRight(root *node[K, V]) *node[K, V] {
+	var goat *node[K, V]
+	root.right, goat = t.popMin(root.right)
+	return goat
+}
+
+// popMin removes the node with the smallest key from the subtree rooted at n,
+// and returns the modified subtree and the removed node. Nodes along the path
+// that are not owned by t are copied; the removed node itself is not modified.
+// This function panics if n == nil.
+func (t *Tree[K, V]) popMin(n *node[K, V]) (_, min *node[K, V]) {
+	var buf [pathBuf]step[K, V]
+	path := buf[:0]
+	for ; n.left != nil; n = n.left {
+		path = append(path, step[K, V]{n, true})
+	}
+	return t.relink(path, n.right), n
+}
+
+// popMax removes the node with the largest key from the subtree rooted at n,
+// and returns the modified subtree and the removed node, as for popMin.
+// This function panics if n == nil.
+func (t *Tree[K, V]) popMax(n *node[K, V]) (_, max *node[K, V]) {
+	var buf [pathBuf]step[K, V]
+	path := buf[:0]
+	for ; n.right != nil; n = n.right {
+		path = append(path, step[K, V]{n, false})
+	}
+	return t.relink(path, n.left), n
+}
+
+// A step is a node on a path descending from the root of a tree, and records
+// which child of the node the path continues to.
+type step[K, V any] struct {
+	n    *node[K, V]
+	left bool // whether the path continues to the left child
+}
+
+// relink replaces the subtree at the end of path with sub, and returns the
+// modified tree. The nodes along path are copied if they are not owned by t,
+// and fixed from the bottom up. If path is empty, relink returns sub.
+func (t *Tree[K, V]) relink(path []step[K, V], sub *node[K, V]) *node[K, V] {
+	for i := len(path) - 1; i >= 0; i-- {
+		n := t.mut(path[i].n)
+		if path[i].left {
+			n.left = sub
+		} else {
+			n.right = sub
+		}
+		t.fix(n)
+		sub = n
+	}
+	return sub
+}
+
+// postorder rebuilds the subtree rooted at n from the bottom up, replacing
+// each node m with the result of f(m, left, right), where left and right are
+// the results for the children of m, and returns the result for n.
+func postorder[K, V any](n *node[K, V], f func(m, left, right *node[K, V]) *node[K, V]) *node[K, V] {
+	if n == nil {
+		return nil
+	}
+	type frame struct {
+		n, left *node[K, V]
+		state   int // 0: visit left subtree; 1: visit right subtree; 2: done
+	}
+	var out *node[K, V] // the result for the most recently finished subtree
+	stack := []frame{{n: n}}
+	for len(stack) != 0 {
+		fr := &stack[len(stack)-1]
+		switch fr.state {
+		case 0:
+			fr.state = 1
+			if kid := fr.n.left; kid != nil {
+				stack = append(stack, frame{n: kid})
+			} else {
+				out = nil
+			}
+		case 1:
+			fr.left, fr.state = out, 2
+			if kid := fr.n.right; kid != nil {
+				stack = append(stack, frame{n: kid})
+			} else {
+				out = nil
+			}
+		case 2:
+			out = f(fr.n, fr.left, out)
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return out
+}
+
+// mut returns n if it is owned by t, or otherwise a copy of n owned by t.
+// The caller must update its reference to n with the result.
+func (t *Tree[K, V]) mut(n *node[K, V]) *node[K, V] {
+	if n.owner() == t.epoch {
+		return n
+	}
+	var cp *node[K, V]
+	l := t.layout()
+	switch l {
+	case aggLayout:
+		cp = t.aug.copy(n)
+	case rankedLayout:
+		r := *n.ranked()
+		cp = &r.node
+	default:
+		c := *n
+		cp = &c
+	}
+	cp.epoch = t.epoch | uint64(l)
+	t.copies++
+	return cp
+}
+
+// relayout copies all the nodes rooted at n into new nodes owned by t, with
+// the layout its settings require, and returns the new subtree.
+func (t *Tree[K, V]) relayout(n *node[K, V]) *node[K, V] {
+	return postorder(n, func(n, left, right *node[K, V]) *node[K, V] {
+		m := t.newNode(n.key, n.value)
+		m.left, m.right = left, right
+		t.fix(m)
+		t.copies++
+		return m
+	})
+}
+
+// walk visits the nodes of the subtree under n inorder, calling f for each.
+func (n *node[K, V]) walk(f func(*node[K, V])) {
+	n.each(func(n *node[K, V]) bool {
+		f(n)
+		return true
+	})
+}
+
+// inorder visits the subtree under n inorder, calling f until f returns false.
+func (n *node[K, V]) inorder(f func(KV[K, V]) bool) bool {
+	return n.each(func(n *node[K, V]) bool {
+		return f(KV[K, V]{Key: n.key, Value: n.value})
+	})
+}
+
+// reverseInorder visits the subtree under n in reverse order, calling f until
+// f returns false.
+func (n *node[K, V]) reverseInorder(f func(KV[K, V]) bool) bool {
+	return n.eachReverse(func(n *node[K, V]) bool {
+		return f(KV[K, V]{Key: n.key, Value: n.value})
+	})
+}
+
+// pathTo returns the sequence of nodes beginning at n leading to key, if key
+// is present. If key was found, its node is the last element of the path.
+//
+// If tie == 0, the path ends at the first node found whose key is equal to
+// key. Otherwise, the path continues past equal nodes, to the left if tie < 0
+// or to the right if tie > 0, so that it leads to the first or last of several
+// nodes with equal keys. In that case, the last element of the path is not
+// necessarily equal to key, even if key is present.
+func (n *node[K, V]) pathTo(key K, compare func(a, b K) int, tie int) []*node[K, V] {
+	var path []*node[K, V]
+	cur := n
+	for cur != nil {
+		path = append(path, cur)
+		c := compare(key, cur.key)
+		if c == 0 {
+			c = tie
+		}
+		if c < 0 {
+			cur = cur.left
+		} else if c > 0 {
+			cur = cur.right
+		} else {
+			break
+		}
+	}
+	return path
+}
+
+// inorderAfter visits the elements of the subtree under n not less than key
+// inorder, calling f for each until f returns false. If tie < 0, the subtree
+// may contain duplicate keys; see pathTo.
+func (n *node[K, V]) inorderAfter(key K, compare func(a, b K) int, tie int, f func(KV[K, V]) bool) {
+	// Find the path from the root to key. Any nodes greater than or equal to
+	// key must be on or to the right of this path.
+	path := n.pathTo(key, compare, tie)
+	for i := len(path) - 1; i >= 0; i-- {
+		cur := path[i]
+		if compare(cur.key, key) < 0 {
+			continue
+		} else if ok := f(KV[K, V]{Key: cur.key, Value: cur.value}); !ok {
+			return
+		} else if ok := cur.right.inorder(f); !ok {
+			return
+		}
+	}
+}
+
+// inorderBefore visits the elements of the subtree under n not greater than
+// key in reverse order, calling f for each until f returns false. If tie > 0,
+// the subtree may contain duplicate keys; see pathTo.
+func (n *node[K, V]) inorderBefore(key K, compare func(a, b K) int, tie int, f func(KV[K, V]) bool) {
+	// Find the path from the root to key. Any nodes less than or equal to key
+	// must be on or to the left of this path.
+	path := n.pathTo(key, compare, tie)
+	for i := len(path) - 1; i >= 0; i-- {
+		cur := path[i]
+		if compare(cur.key, key) > 0 {
+			continue
+		} else if ok := f(KV[K, V]{Key: cur.key, Value: cur.value}); !ok {
+			return
+		} else if ok := cur.left.reverseInorder(f); !ok {
+			return
+		}
+	}
+}
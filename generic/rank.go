@@ -0,0 +1,95 @@
+package generic
+
+import "fmt"
+
+// SetRanked enables or disables order-statistic bookkeeping for t.
+//
+// When t is ranked, each node records the size of its subtree, so that Rank,
+// Select, and At take O(lg n) time. This costs one word per node, and a small
+// amount of additional work for each insertion and deletion. Enabling ranking
+// on a non-empty tree takes O(n) time to copy its nodes into a layout with
+// room for the counts.
+//
+// When t is not ranked (the default), the order-statistic methods still work,
+// but each query takes time proportional to the rank of its result.
+func (t *Tree[K, V]) SetRanked(ranked bool) {
+	if ranked != t.ranked {
+		t.completeRebuild()
+	}
+	if ranked && !t.ranked {
+		t.ranked = true
+		t.root = t.relayout(t.root) // copy the nodes into the ranked layout
+	}
+	t.ranked = ranked
+}
+
+// Rank reports the number of keys in t strictly less than key. If key is
+// present in t, this is its index in the inorder sequence.
+func (t *Tree[K, V]) Rank(key K) int {
+	var rank int
+	if !t.ranked {
+		t.root.inorder(func(kv KV[K, V]) bool {
+			if t.compare(kv.Key, key) >= 0 {
+				return false
+			}
+			rank++
+			return true
+		})
+		return rank
+	}
+	cur := t.root
+	for cur != nil {
+		if c := t.compare(key, cur.key); c < 0 || (c == 0 && t.multi) {
+			cur = cur.left // in a multimap, there may be equal keys to the left
+		} else if c > 0 {
+			rank += cur.left.weight() + 1
+			cur = cur.right
+		} else {
+			return rank + cur.left.weight()
+		}
+	}
+	return rank
+}
+
+// At returns the key/value pair at index i in the inorder sequence of t, and
+// reports whether 0 ≤ i < t.Len(). If i is out of range, At returns a zero
+// KV and false.
+func (t *Tree[K, V]) At(i int) (KV[K, V], bool) {
+	if i < 0 || i >= t.size {
+		return KV[K, V]{}, false
+	} else if !t.ranked {
+		var out KV[K, V]
+		t.root.inorder(func(kv KV[K, V]) bool {
+			if i == 0 {
+				out = kv
+				return false
+			}
+			i--
+			return true
+		})
+		return out, true
+	}
+	cur := t.root
+	for {
+		if n := cur.left.weight(); i < n {
+			cur = cur.left
+		} else if i > n {
+			i -= n + 1
+			cur = cur.right
+		} else {
+			return KV[K, V]{Key: cur.key, Value: cur.value}, true
+		}
+	}
+}
+
+// Select returns the key/value pair at index i in the inorder sequence of t.
+// It is the inverse of Rank for keys present in t.
+//
+// Select panics if i < 0 or i ≥ t.Len().
+func (t *Tree[K, V]) Select(i int) KV[K, V] {
+	kv, ok := t.At(i)
+	if !ok {
+		panic(fmt.Sprintf("index %d out of range [0:%d]", i, t.size))
+	}
+	return kv
+}
@@ -0,0 +1,125 @@
+package generic
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkCounts verifies that the subtree counts of the nodes under n are
+// consistent with the shape of the tree.
+func checkCounts[K, V any](t *testing.T, n *node[K, V]) int {
+	t.Helper()
+	if n == nil {
+		return 0
+	}
+	want := 1 + checkCounts(t, n.left) + checkCounts(t, n.right)
+	if got := n.weight(); got != want {
+		t.Errorf("Node %v: count is %d, want %d", n.key, got, want)
+	}
+	return want
+}
+
+func TestRank(t *testing.T) {
+	rng := rand.New(rand.NewSource(20201019))
+	for _, β := range []int{0, 150, 500, 1000} {
+		tree := New[int, int](β)
+		tree.SetRanked(true)
+
+		// Insert and remove a bunch of random keys, keeping track of which
+		// ones are still present.
+		present := make(map[int]bool)
+		for i := 0; i < 2000; i++ {
+			key := rng.Intn(1500)
+			if rng.Intn(3) == 0 {
+				if tree.Remove(key) != present[key] {
+					t.Fatalf("β=%d: Remove(%d) disagrees with presence %v", β, key, present[key])
+				}
+				delete(present, key)
+			} else {
+				tree.Replace(key, -key)
+				present[key] = true
+			}
+		}
+		checkCounts(t, tree.root)
+
+		var keys []int
+		for key := range present {
+			keys = append(keys, key)
+		}
+		sort.Ints(keys)
+		if tree.Len() != len(keys) {
+			t.Fatalf("β=%d: Len is %d, want %d", β, tree.Len(), len(keys))
+		}
+
+		check := func(mode string) {
+			for i, key := range keys {
+				if got := tree.Rank(key); got != i {
+					t.Errorf("β=%d %s: Rank(%d) = %d, want %d", β, mode, key, got, i)
+				}
+				if got := tree.Rank(key + 1); key+1 < 1500 && !present[key+1] && got != i+1 {
+					t.Errorf("β=%d %s: Rank(%d) = %d, want %d", β, mode, key+1, got, i+1)
+				}
+				if got := tree.Select(i); got.Key != key || got.Value != -key {
+					t.Errorf("β=%d %s: Select(%d) = %+v, want %d", β, mode, i, got, key)
+				}
+			}
+			for _, i := range []int{-1, len(keys)} {
+				if got, ok := tree.At(i); ok {
+					t.Errorf("β=%d %s: At(%d) = %+v, want none", β, mode, i, got)
+				}
+			}
+			if got := tree.Rank(-1); got != 0 {
+				t.Errorf("β=%d %s: Rank(-1) = %d, want 0", β, mode, got)
+			}
+			if got := tree.Rank(1500); got != len(keys) {
+				t.Errorf("β=%d %s: Rank(1500) = %d, want %d", β, mode, got, len(keys))
+			}
+		}
+		check("ranked")
+		tree.SetRanked(false)
+		check("unranked")
+	}
+}
+
+func TestSetRanked(t *testing.T) {
+	tree := New[string, int](100)
+	for i, w := range []string{"one", "two", "three", "four", "five"} {
+		tree.Insert(w, i)
+	}
+	tree.Remove("three")
+	tree.SetRanked(true)
+	checkCounts(t, tree.root)
+
+	if got := tree.Rank("one"); got != 2 {
+		t.Errorf("Rank(one) = %d, want 2", got)
+	}
+	if got := tree.Select(3).Key; got != "two" {
+		t.Errorf("Select(3) = %q, want two", got)
+	}
+
+	// Nodes added while unranked lack counts, and a clone shares them, so
+	// ranking the clone again must not disturb the original.
+	tree.SetRanked(false)
+	tree.Insert("six", 5)
+	tree.Insert("seven", 6)
+	clone := tree.Clone()
+	clone.SetRanked(true)
+	clone.Insert("eight", 7)
+	checkCounts(t, clone.root)
+	checkTree(t, clone)
+	checkTree(t, tree)
+	if got := clone.Rank("seven"); got != 4 {
+		t.Errorf("Rank(seven) = %d, want 4", got)
+	}
+	if got := tree.Len(); got != 6 {
+		t.Errorf("Len = %d, want 6", got)
+	}
+
+	defer func() {
+		if x := recover(); x == nil {
+			t.Error("Select(7) did not panic")
+		}
+	}()
+	clone.Select(7)
+}
@@ -0,0 +1,326 @@
+package generic
+
+import (
+	"fmt"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// SetRebuildQuantum enables deamortized rebuilding for t with the given work
+// quantum q > 0, or disables it if q ≤ 0, which is the default.
+//
+// Ordinarily, an insertion or removal that unbalances t rebuilds the offending
+// subtree at once, taking time proportional to its size: up to O(n) for a
+// single operation. When t is deamortized, small rebuilds are still done at
+// once, but a larger rebuild is instead carried out incrementally. The tree
+// begins building a balanced copy of its contents, and each subsequent Insert,
+// Replace, Remove, PopMin, PopMax, or Update copies a share of its nodes. When
+// all the nodes have been copied, the keys updated in the meantime are
+// reapplied to the copy, at O(lg n) each, and the copy replaces the contents
+// of t. While the copy is in progress, t holds up to n additional nodes.
+//
+// While a rebuild is in progress, an insertion that would have triggered a
+// large rebuild instead deepens the tree. To preserve the height bound, each
+// rebuild is paced to finish within g updates, where g is the difference
+// between the depth limit for the balancing factor of t and the depth of a
+// perfectly balanced tree of its size. Each update thus copies max(q, ⌈n/g⌉)
+// nodes, and rebuilds up to that size are done at once. The height of t
+// exceeds the usual bound by at most about g levels, which Stats reports as
+// the excess. Since g grows in proportion to lg n, and faster for looser
+// balancing factors, the height remains O(lg n). For β near 0 there is
+// little or no slack, and every rebuild is done at once.
+//
+// Only single-key updates are deamortized. The batch operations, RemoveRange,
+// RemoveIf, Split, Join, SetRanked, SetMulti, and Augment complete any rebuild
+// in progress at once, in time proportional to the size of t.
+//
+// Disabling deamortization completes any rebuild in progress, and rebuilds t
+// if necessary to restore the usual height bound.
+func (t *Tree[K, V]) SetRebuildQuantum(q int) {
+	if q > 0 {
+		t.quantum = q
+		return
+	}
+	t.quantum = 0
+	t.FinishRebuild()
+}
+
+// FinishRebuild completes any deamortized rebuild of t that is in progress or
+// pending, in time proportional to the size of t, and restores the usual
+// height bound for t. See SetRebuildQuantum.
+func (t *Tree[K, V]) FinishRebuild() {
+	if t.pending == nil && t.excess == 0 && t.loose == 0 {
+		return
+	}
+	if t.pending != nil {
+		t.stats.count(t.pending.cause)
+	}
+	t.Rebalance()
+}
+
+// completeRebuild completes any deamortized rebuild of t in progress, for an
+// operation whose changes cannot be reapplied to the copy.
+func (t *Tree[K, V]) completeRebuild() {
+	if t.pending != nil {
+		t.FinishRebuild()
+	}
+}
+
+// pace returns the number of updates within which a deferred rebuild of a
+// tree of n > 0 nodes must finish: the number of levels by which the depth
+// limit for n exceeds the depth of a perfectly balanced tree, but at least 1.
+func (t *Tree[K, V]) pace(n int) int {
+	return max(1, t.limit(n)-(bits.Len(uint(n))-1))
+}
+
+// chunk returns the number of nodes a deamortized tree of n nodes copies per
+// update while rebuilding, which is also the largest rebuild it does at once.
+func (t *Tree[K, V]) chunk(n int) int {
+	if n == 0 {
+		return t.quantum
+	}
+	g := t.pace(n)
+	return max(t.quantum, (n+g-1)/g)
+}
+
+// A rebuildCause identifies the kind of operation that triggered a rebuild.
+type rebuildCause int
+
+const (
+	byInsert rebuildCause = iota
+	byRemove
+	byOther // not counted by Stats, as for Join
+)
+
+// count records a rebuild triggered by cause.
+func (s *rebuildStats) count(cause rebuildCause) {
+	switch cause {
+	case byInsert:
+		s.inserts++
+	case byRemove:
+		s.removes++
+	}
+}
+
+// rebuild rewrites the subtree rooted at root, whose size and depth are
+// given, and reports whether it did so. If t is deamortized and a removal
+// calls for rebuilding more than a chunk of nodes, rebuild instead schedules
+// an incremental rebuild of the whole tree, and returns root unchanged. (An
+// insertion makes that choice itself; see rodeo.) If rebuilding is disabled,
+// as for the copy under construction by an incremental rebuild, rebuild does
+// nothing.
+func (t *Tree[K, V]) rebuild(root *node[K, V], size, depth int, cause rebuildCause) (*node[K, V], bool) {
+	if t.quantum < 0 {
+		return root, false
+	} else if t.quantum > 0 && cause == byRemove && size > t.chunk(t.size) {
+		t.deferRebuild(cause)
+		return root, false
+	}
+	t.stats.count(cause)
+	root = t.rewrite(root, size, depth)
+	if depth == 0 {
+		// The whole tree is now balanced.
+		t.pending, t.excess, t.loose = nil, 0, 0
+	}
+	return root, true
+}
+
+// deferRebuild schedules an incremental rebuild of t, if one is not already
+// pending. The rebuild starts with the next single-key update.
+func (t *Tree[K, V]) deferRebuild(cause rebuildCause) {
+	if t.pending == nil {
+		t.pending = &rebuilder[K, V]{cause: cause}
+	}
+}
+
+// A rebuilder records the state of an incremental rebuild.
+//
+// The rebuild copies a frozen snapshot of the tree, taken when it starts,
+// into a new balanced tree. Meanwhile, the keys updated in the live tree are
+// logged. Once the copy is built, the log is replayed by copying the current
+// nodes for each key from the live tree. The copy then replaces the contents
+// of the live tree.
+type rebuilder[K, V any] struct {
+	cause rebuildCause
+	b     *Tree[K, V] // the copy, or nil if the rebuild has not started
+	size  int         // the number of nodes in the snapshot
+	chunk int         // the number of nodes to copy per update
+
+	src    []*node[K, V]      // inorder iterator over the snapshot
+	frames []buildFrame[K, V] // stack of subtrees under construction
+	result *node[K, V]        // the most recently completed subtree
+	log    []K                // keys updated since the rebuild began
+	built  bool               // whether the copy is complete
+	spent  time.Duration      // time spent so far, if there is a hook
+}
+
+// A buildFrame is a subtree under construction by a rebuilder.
+type buildFrame[K, V any] struct {
+	size  int // the number of nodes in the subtree
+	state int // 0: build left subtree; 1: copy root; 2: link right subtree
+	root  *node[K, V]
+}
+
+// startRebuild starts the pending rebuild of t.
+func (t *Tree[K, V]) startRebuild() {
+	r := t.pending
+	r.b = t.empty()
+	r.b.quantum = -1 // the copy must not rebuild itself
+	r.b.hook = nil
+	r.size = t.size
+	r.chunk = t.chunk(r.size)
+	for n := t.root; n != nil; n = n.left {
+		r.src = append(r.src, n)
+	}
+	if r.size != 0 {
+		r.frames = append(r.frames, buildFrame[K, V]{size: r.size})
+	}
+
+	// Freeze the snapshot: Henceforth t will copy any node it modifies.
+	t.epoch = newEpoch()
+}
+
+// clone returns a copy of r for a clone of its tree, so that the clone can
+// continue the rebuild independently. The completed subtrees of the copy under
+// construction are shared, but the roots still waiting for their right
+// subtrees will be modified, so each rebuilder gets its own copies of them.
+func (r *rebuilder[K, V]) clone() *rebuilder[K, V] {
+	cp := *r
+	if r.b == nil {
+		return &cp
+	}
+	cp.b = r.b.Clone()
+	cp.src = slices.Clone(r.src)
+	cp.log = slices.Clone(r.log)
+	cp.frames = slices.Clone(r.frames)
+	for i, f := range r.frames {
+		if f.root != nil {
+			r.frames[i].root = r.b.mut(f.root)
+			cp.frames[i].root = cp.b.mut(f.root)
+		}
+	}
+	return &cp
+}
+
+// advance is called after each single-key update of t, which may have
+// modified the nodes with the given key. It advances any incremental rebuild
+// of t by one chunk of work.
+func (t *Tree[K, V]) advance(key K) {
+	r := t.pending
+	if r == nil || t.quantum <= 0 {
+		return
+	} else if r.b == nil {
+		t.startRebuild() // the snapshot includes this update
+	} else {
+		r.log = append(r.log, key)
+	}
+	t.stepRebuild()
+}
+
+// stepRebuild copies one chunk of nodes into the copy under construction by
+// the incremental rebuild of t. If that completes the copy, it then replays
+// the log and installs the copy.
+func (t *Tree[K, V]) stepRebuild() {
+	r := t.pending
+	start := t.rebuildStart()
+	for i := 0; i < r.chunk && !r.built; i++ {
+		t.buildStep()
+	}
+	if r.built {
+		// Each update logs one key, so the log is no longer than the number of
+		// updates needed to build the copy.
+		for _, key := range r.log {
+			t.replay(key)
+		}
+		r.log = nil
+	}
+	if t.hook != nil {
+		r.spent += time.Since(start)
+	}
+	if !r.built {
+		return
+	}
+
+	// The copy is complete and current; replace the contents of t.
+	b := r.b
+	if b.size != t.size {
+		panic(fmt.Sprintf("rebuilt %d nodes but tree size is %d", b.size, t.size))
+	}
+	t.root, t.epoch = b.root, b.epoch
+	t.max, t.excess, t.loose = b.max, b.excess, 0
+	t.mods++
+	t.pending = nil
+	t.stats.count(r.cause)
+	t.report(r.size, 0, r.spent)
+	if t.excess != 0 {
+		// Updates replayed into the copy left it too deep; start over.
+		t.deferRebuild(r.cause)
+	}
+}
+
+// buildStep copies one node of the snapshot into the copy under construction,
+// together with any bookkeeping for the subtrees it completes.
+func (t *Tree[K, V]) buildStep() {
+	r := t.pending
+	for len(r.frames) != 0 {
+		f := &r.frames[len(r.frames)-1]
+		mid := (f.size - 1) / 2 // as for extract
+		switch f.state {
+		case 0:
+			f.state = 1
+			if mid > 0 {
+				r.frames = append(r.frames, buildFrame[K, V]{size: mid})
+			} else {
+				r.result = nil
+			}
+		case 1:
+			f.state = 2
+			n := r.src[len(r.src)-1]
+			r.src = r.src[:len(r.src)-1]
+			for c := n.right; c != nil; c = c.left {
+				r.src = append(r.src, c)
+			}
+			f.root = r.b.newNode(n.key, n.value)
+			f.root.left = r.result
+			if rest := f.size - 1 - mid; rest > 0 {
+				r.frames = append(r.frames, buildFrame[K, V]{size: rest})
+			} else {
+				r.result = nil
+			}
+			if len(r.src) != 0 {
+				return
+			}
+			// That was the last node; finish the pending subtrees now, so that
+			// copying n nodes takes exactly n steps.
+		case 2:
+			f.root.right = r.result
+			r.b.fix(f.root)
+			r.result = f.root
+			r.frames = r.frames[:len(r.frames)-1]
+		}
+	}
+
+	// All the nodes have been copied.
+	r.b.root = r.result
+	r.b.size, r.b.max = r.size, r.size
+	r.built, r.result = true, nil
+}
+
+// replay updates the copy under construction so that its nodes with key match
+// those of t.
+func (t *Tree[K, V]) replay(key K) {
+	b := t.pending.b
+	if t.multi {
+		for b.Remove(key) {
+		}
+		t.LookupAll(key, func(v V) bool {
+			b.Insert(key, v)
+			return true
+		})
+	} else if v, ok := t.Lookup(key); ok {
+		b.Replace(key, v)
+	} else {
+		b.Remove(key)
+	}
+}
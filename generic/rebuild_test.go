@@ -0,0 +1,286 @@
+package generic
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDeamortized(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// For β near 0 there is no slack for deferred rebuilds; see pace.
+	for _, β := range []int{300, 600} {
+		for _, ranked := range []bool{false, true} {
+			tree := intTree(β, ranked, nil)
+			sum := Augment(tree, sumValues)
+			tree.SetRebuildQuantum(8)
+			want := make(map[int]int)
+
+			var snap *Tree[int, int]
+			var snapWant []KV[int, int]
+			rebuilding := 0
+			for i := 0; i < 3000; i++ {
+				// Favour insertions early and removals late, so that the tree
+				// both grows and shrinks far enough to rebuild.
+				key := rng.Intn(1000)
+				if i < 1500 {
+					key = i // ascending keys force deep insertions
+				}
+				switch op := rng.Intn(10); {
+				case op < 4 && i < 2000:
+					tree.Insert(key, i)
+					if _, ok := want[key]; !ok {
+						want[key] = i
+					}
+				case op < 5:
+					_, had := want[key]
+					if tree.Replace(key, i) == had {
+						t.Errorf("Replace(%d): got added=%v, want %v", key, had, !had)
+					}
+					want[key] = i
+				case op < 6:
+					tree.Upsert(key, func(v int, _ bool) int { return v + 1 })
+					want[key]++
+				case op < 7:
+					if kv, ok := tree.PopMin(); ok {
+						delete(want, kv.Key)
+					}
+				default:
+					tree.Remove(key)
+					delete(want, key)
+				}
+				if err := tree.Check(); err != nil {
+					t.Fatalf("β=%d, op %d: Check: %v", β, i, err)
+				}
+				if tree.Stats().Rebuilding {
+					rebuilding++
+				}
+				if i == 1000 {
+					snap, snapWant = tree.Clone(), contents(tree)
+				}
+			}
+			checkTree(t, tree)
+			checkAggs(t, sum.m, tree.root)
+			if diff := cmp.Diff(mapContents(want), contents(tree)); diff != "" {
+				t.Errorf("β=%d: contents (-want, +got)\n%s", β, diff)
+			}
+			if diff := cmp.Diff(snapWant, contents(snap)); diff != "" {
+				t.Errorf("β=%d: snapshot changed (-want, +got)\n%s", β, diff)
+			}
+			checkTree(t, snap)
+
+			s := tree.Stats()
+			t.Logf("β=%d ranked=%v: rebuilding after %d ops; %+v", β, ranked, rebuilding, s)
+			if rebuilding == 0 {
+				t.Errorf("β=%d: no incremental rebuild occurred", β)
+			}
+
+			// Disabling deamortization restores the usual height bound.
+			tree.SetRebuildQuantum(0)
+			if s := tree.Stats(); s.Excess != 0 || s.Rebuilding {
+				t.Errorf("After SetRebuildQuantum(0): got %+v, want no excess", s)
+			}
+			checkTree(t, tree)
+			if diff := cmp.Diff(mapContents(want), contents(tree)); diff != "" {
+				t.Errorf("β=%d: contents after rebuild (-want, +got)\n%s", β, diff)
+			}
+		}
+	}
+}
+
+func TestDeamortizedWork(t *testing.T) {
+	const quantum = 16
+	tree := New[int, int](300)
+	tree.SetRebuildQuantum(quantum)
+	var events []RebuildEvent
+	tree.OnRebuild(func(e RebuildEvent) { events = append(events, e) })
+
+	// Rebuilds larger than a chunk are reported only when an incremental
+	// rebuild that is already under way completes, and never by the operation
+	// that triggers them.
+	completed := 0
+	for _, k := range intRange(0, 5000, 1) {
+		events = nil
+		started := tree.pending != nil && tree.pending.b != nil
+		chunk := tree.chunk(tree.Len() + 1)
+		tree.Insert(k, k)
+		for _, e := range events {
+			if e.Size <= chunk {
+				continue
+			} else if !started {
+				t.Fatalf("Insert(%d): synchronous rebuild of %d nodes, chunk is %d", k, e.Size, chunk)
+			}
+			completed++
+		}
+	}
+	s := tree.Stats()
+	t.Logf("Completed %d incremental rebuilds; %+v", completed, s)
+	if completed == 0 || s.LargestRebuild <= tree.chunk(s.Size) {
+		t.Errorf("No large incremental rebuild was completed: %+v", s)
+	}
+	if s.Height > tree.limit(s.Max)+s.Excess+1 {
+		t.Errorf("Height %d exceeds limit %d plus excess %d", s.Height, tree.limit(s.Max), s.Excess)
+	}
+
+	// FinishRebuild completes any rebuild at once.
+	tree.FinishRebuild()
+	if s := tree.Stats(); s.Rebuilding || s.Excess != 0 {
+		t.Errorf("After FinishRebuild: %+v", s)
+	}
+	if got, want := tree.Stats().Height, tree.limit(tree.Len())+1; got > want {
+		t.Errorf("After FinishRebuild: height %d, want ≤ %d", got, want)
+	}
+	checkTree(t, tree)
+}
+
+func TestDeamortizedMulti(t *testing.T) {
+	tree := New[int, int](400)
+	tree.SetMulti(true)
+	tree.SetRebuildQuantum(4)
+	var model multiModel
+	for i := 0; i < 600; i++ {
+		key := i / 3
+		if i%5 == 4 {
+			key = (i * 7) % (i/3 + 1)
+			tree.Remove(key)
+			model.remove(key)
+		} else {
+			tree.Insert(key, i)
+			model.insert(key, i)
+		}
+		if err := tree.Check(); err != nil {
+			t.Fatalf("Op %d: Check: %v", i, err)
+		}
+	}
+	tree.FinishRebuild()
+	checkTree(t, tree)
+	if diff := cmp.Diff([]KV[int, int](model), contents(tree)); diff != "" {
+		t.Errorf("Contents (-want, +got)\n%s", diff)
+	}
+}
+
+func TestDeamortizedHeight(t *testing.T) {
+	// Ascending insertions deepen the tree on almost every update, so while a
+	// rebuild is in progress, the excess grows as fast as it can.
+	const numKeys = 200000
+	for _, quantum := range []int{8, 64} {
+		tree := New[int, int](200)
+		tree.SetRebuildQuantum(quantum)
+		worst := 0
+		for i := 0; i < numKeys; i++ {
+			tree.Insert(i, i)
+
+			// The rebuild is paced to finish before the excess can exceed the
+			// slack between the depth limit and a perfectly balanced tree.
+			if g := tree.pace(tree.max); tree.excess > g {
+				t.Fatalf("q=%d, Insert(%d): excess %d exceeds pace %d", quantum, i, tree.excess, g)
+			}
+			worst = max(worst, tree.excess)
+		}
+		s := tree.Stats()
+		limit, g := tree.limit(numKeys), tree.pace(numKeys)
+		t.Logf("q=%d: height %d, limit %d, pace %d, worst excess %d", quantum, s.Height, limit, g, worst)
+		if s.Height-1 > limit+g {
+			t.Errorf("q=%d: height %d exceeds limit %d + %d", quantum, s.Height, limit, g)
+		}
+		checkTree(t, tree)
+	}
+}
+
+func TestDeamortizedClone(t *testing.T) {
+	tree := New[int, int](300)
+	tree.SetRebuildQuantum(4)
+
+	// Insert keys until a rebuild long enough to interrupt begins.
+	next := 0
+	for r := tree.pending; r == nil || r.b == nil || len(r.log) != 0 || r.size < 500; r = tree.pending {
+		tree.Insert(next, next)
+		next++
+	}
+	r := tree.pending
+	total := (r.size + r.chunk - 1) / r.chunk // updates to finish the rebuild
+	for done := 1; done < total/2; done++ {
+		tree.Insert(next, next)
+		next++
+	}
+
+	// A clone made partway through a rebuild continues it independently, and
+	// finishes it no later than the original does.
+	clone := tree.Clone()
+	finished := make(map[*Tree[int, int]]bool)
+	want := make(map[*Tree[int, int]]map[int]int)
+	for _, u := range []*Tree[int, int]{tree, clone} {
+		u := u
+		u.OnRebuild(func(e RebuildEvent) {
+			if e.Size == r.size {
+				finished[u] = true
+			}
+		})
+		want[u] = make(map[int]int)
+		for _, kv := range contents(u) {
+			want[u][kv.Key] = kv.Value
+		}
+	}
+	for i := 0; i < total-total/2; i++ {
+		for j, u := range []*Tree[int, int]{tree, clone} {
+			key := next + i*2 + j
+			u.Insert(key, key)
+			want[u][key] = key
+			u.Remove(i)
+			delete(want[u], i)
+			checkTree(t, u)
+		}
+	}
+	for _, u := range []*Tree[int, int]{tree, clone} {
+		if !finished[u] {
+			t.Errorf("Rebuild of %d nodes did not finish: %+v", r.size, u.Stats())
+		}
+		if diff := cmp.Diff(mapContents(want[u]), contents(u)); diff != "" {
+			t.Errorf("Contents (-want, +got)\n%s", diff)
+		}
+	}
+}
+
+func TestDeamortizedTightPace(t *testing.T) {
+	// A strict factor leaves no slack, so a deferred rebuild must copy the
+	// whole tree and finish within the update that advances it. Tightening the
+	// factor of a loose tree midway through a rebuild must not exceed that.
+	tree := New[int, int](800)
+	tree.SetMulti(true)
+	tree.SetRebuildQuantum(5)
+	for _, k := range intRange(0, 200, 1) {
+		tree.Insert(k, k)
+	}
+	if r := tree.pending; r == nil || r.b == nil || len(r.log) == 0 {
+		t.Fatal("No rebuild is in progress")
+	}
+	tree.SetBalance(55, false)
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		switch op := rng.Intn(10); {
+		case op < 6:
+			tree.Insert(rng.Intn(300), i)
+		case op < 8:
+			tree.PopMax()
+		case op < 9:
+			k := rng.Intn(300)
+			tree.InsertAll(KV[int, int]{k, i}, KV[int, int]{k + 1, i})
+		default:
+			tree.Remove(rng.Intn(300))
+		}
+		if g := tree.pace(tree.max); g != 1 {
+			t.Fatalf("Op %d: pace is %d, want 1", i, g)
+		} else if tree.Stats().Rebuilding {
+			t.Fatalf("Op %d: rebuild is still in progress", i)
+		}
+		if err := tree.Check(); err != nil {
+			t.Fatalf("Op %d: Check: %v", i, err)
+		}
+	}
+	if s := tree.Stats(); s.InsertRebuilds+s.RemoveRebuilds == 0 {
+		t.Errorf("No rebuilds occurred: %+v", s)
+	}
+	checkTree(t, tree)
+}
@@ -0,0 +1,723 @@
+// Package generic implements a Scapegoat Tree, as described in the paper
+//
+//	I. Galperin, R. Rivest: "Scapegoat Trees"
+//	https://people.csail.mit.edu/rivest/pubs/GR93.pdf
+//
+// A scapegoat tree is an approximately-balanced binary search tree structure
+// with worst-case O(lg n) lookup and amortized O(lg n) insert and delete.  The
+// worst-case cost of a single insert or delete is O(n).
+//
+// It is also relatively memory-efficient, as interior nodes do not require any
+// ancillary metadata for balancing purposes, and the tree itself costs only a
+// few words of bookkeeping overhead beyond the nodes. A rebalancing operation
+// requires only a single contiguous vector allocation. Besides its key, value,
+// and children, a node records only an epoch, which trees use to share nodes
+// with their clones. Ranked trees (see Tree.SetRanked) and augmented trees
+// (see Augment) use larger nodes that also record the size or aggregate of
+// their subtrees; other trees do not pay for these.
+//
+// The Tree type in this package is parameterized by its key and value types.
+// Use New to construct a tree for a naturally-ordered key type, or NewFunc to
+// supply a comparison function for other key types.
+package generic
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// A KV combines a key with a value. Values are not interpreted, and may be
+// zero if the key records all the information of interest.
+type KV[K, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+const (
+	maxBalance = 1000
+	fracLimit  = 2 * maxBalance
+)
+
+// New returns *Tree with the given balancing factor 0 ≤ β ≤ 1000 and keys.
+// Keys are ordered by their natural order, as defined by cmp.Compare. If kvs
+// contains duplicate keys, only the last of them is kept, as if each pair had
+// been added in order by Replace.
+// The balancing factor represents how unbalanced the tree is permitted to be,
+// with 0 being strictest (as near as possible to 50% weight balance) and 1000
+// being loosest (no rebalancing).
+//
+// New panics if β < 0 or β > 1000.
+func New[K cmp.Ordered, V any](β int, kvs ...KV[K, V]) *Tree[K, V] {
+	return NewFunc(β, cmp.Compare[K], kvs...)
+}
+
+// NewFunc returns *Tree with the given balancing factor 0 ≤ β ≤ 1000 and keys,
+// ordered by the given comparison function. The compare function must return
+// a negative number if a < b, a positive number if a > b, and zero if a and b
+// are equivalent. See New for a description of the balancing factor and the
+// treatment of duplicate keys.
+//
+// NewFunc panics if β < 0 or β > 1000.
+func NewFunc[K, V any](β int, compare func(a, b K) int, kvs ...KV[K, V]) *Tree[K, V] {
+	if β < 0 || β > maxBalance {
+		panic("β out of range")
+	}
+	tree := &Tree[K, V]{
+		β:       β,
+		compare: compare,
+		limit:   limitFunc(β),
+		epoch:   newEpoch(),
+	}
+	if len(kvs) != 0 {
+		nodes := make([]*node[K, V], len(kvs))
+		for i, kv := range kvs {
+			nodes[i] = tree.newNode(kv.Key, kv.Value)
+		}
+		nodes = sortUnique(nodes, compare, false)
+		tree.root = tree.extract(nodes)
+		tree.size, tree.max = len(nodes), len(nodes)
+	}
+	return tree
+}
+
+// NewFromSorted returns *Tree with the given balancing factor 0 ≤ β ≤ 1000,
+// containing the key/value pairs produced by seq, which must be in strictly
+// increasing order of key. Keys are ordered by their natural order, as for
+// New.
+//
+// The pairs are consumed in a single pass, and the tree is built directly in
+// balanced form without sorting. If seq produces a key that is out of order
+// or a duplicate of the previous key, NewFromSorted stops consuming seq and
+// reports an error.
+//
+// NewFromSorted panics if β < 0 or β > 1000.
+func NewFromSorted[K cmp.Ordered, V any](β int, seq func(yield func(KV[K, V]) bool)) (*Tree[K, V], error) {
+	return NewFromSortedFunc(β, cmp.Compare[K], seq)
+}
+
+// NewFromSortedFunc returns *Tree with the given balancing factor 0 ≤ β ≤ 1000,
+// containing the key/value pairs produced by seq, which must be in strictly
+// increasing order by the given comparison function. See NewFromSorted.
+//
+// NewFromSortedFunc panics if β < 0 or β > 1000.
+func NewFromSortedFunc[K, V any](β int, compare func(a, b K) int, seq func(yield func(KV[K, V]) bool)) (*Tree[K, V], error) {
+	tree := NewFunc[K, V](β, compare)
+	var nodes []*node[K, V]
+	var err error
+	seq(func(kv KV[K, V]) bool {
+		if n := len(nodes); n != 0 {
+			if c := compare(nodes[n-1].key, kv.Key); c == 0 {
+				err = fmt.Errorf("entry %d: duplicate key %v", n, kv.Key)
+			} else if c > 0 {
+				err = fmt.Errorf("entry %d: key %v is out of order", n, kv.Key)
+			}
+		}
+		if err != nil {
+			return false
+		}
+		nodes = append(nodes, tree.newNode(kv.Key, kv.Value))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree.root = tree.extract(nodes)
+	tree.size, tree.max = len(nodes), len(nodes)
+	return tree, nil
+}
+
+// inOrder reports whether c, the comparison of two adjacent keys, shows them to
+// be in order. Equal keys are in order only if multi is true.
+func inOrder(c int, multi bool) bool { return c < 0 || (c == 0 && multi) }
+
+// stableSort sorts nodes in place by key, preserving the relative order of
+// nodes with equal keys.
+func stableSort[K, V any](nodes []*node[K, V], compare func(a, b K) int) {
+	// This is considerably faster than sort.SliceStable, at the cost of an
+	// extra allocation to record the original positions of the nodes.
+	pos := make([]int, len(nodes))
+	for i := range pos {
+		pos[i] = i
+	}
+	sort.Sort(nodesByKey[K, V]{nodes: nodes, pos: pos, compare: compare})
+}
+
+// nodesByKey implements sort.Interface to order nodes by key, and then by
+// original position.
+type nodesByKey[K, V any] struct {
+	nodes   []*node[K, V]
+	pos     []int
+	compare func(a, b K) int
+}
+
+func (s nodesByKey[K, V]) Len() int { return len(s.nodes) }
+
+func (s nodesByKey[K, V]) Less(i, j int) bool {
+	if c := s.compare(s.nodes[i].key, s.nodes[j].key); c != 0 {
+		return c < 0
+	}
+	return s.pos[i] < s.pos[j]
+}
+
+func (s nodesByKey[K, V]) Swap(i, j int) {
+	s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i]
+	s.pos[i], s.pos[j] = s.pos[j], s.pos[i]
+}
+
+// sortUnique sorts nodes in place by key, and unless multi is true, removes
+// all but the last of any nodes with equal keys. It returns the resulting
+// prefix of nodes. If nodes are already in order, no sorting is done.
+func sortUnique[K, V any](nodes []*node[K, V], compare func(a, b K) int, multi bool) []*node[K, V] {
+	for i := 1; i < len(nodes); i++ {
+		if !inOrder(compare(nodes[i-1].key, nodes[i].key), multi) {
+			stableSort(nodes, compare)
+			break
+		}
+	}
+	if multi {
+		return nodes
+	}
+	out := nodes[:0]
+	for i, n := range nodes {
+		if i+1 < len(nodes) && compare(n.key, nodes[i+1].key) == 0 {
+			continue // a later node has the same key
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// A Tree is the root of a scapegoat tree. A *Tree is not safe for concurrent
+// use without external synchronization.
+type Tree[K, V any] struct {
+	root *node[K, V]
+
+	// β identifies a point on the interval [maxBalance,fracLimit], and we
+	// compute the balance fraction as β/fracLimit. This permits breakpoint
+	// computations to use only fixed-point integer arithmetic and only
+	// requires one floating-point operation per insertion to recompute the
+	// depth limit.
+
+	β       int                // balancing factor
+	compare func(a, b K) int   // key comparison
+	limit   func(n int) int    // depth limit for size n
+	size    int                // cache of root.size()
+	max     int                // max of size since last rebuild of root
+	ranked  bool               // whether node counts are maintained
+	aug     augmenter[K, V]    // maintains node aggregates, or nil
+	multi   bool               // whether duplicate keys are permitted
+	mods    int                // count of structural changes, for cursors
+	copies  int                // count of nodes copied on write, for cursors
+	stats   rebuildStats       // rebuild counters, for Stats
+	hook    func(RebuildEvent) // called after each rebuild, or nil
+	quantum int                // rebuild work per update if > 0; see SetRebuildQuantum
+	excess  int                // bound on depth beyond the limit, from deferred rebuilds
+	loose   int                // bound on depth beyond the limit, from tightening β
+	pending *rebuilder[K, V]   // a deferred rebuild, or nil
+	epoch   uint64             // tag for nodes this tree may modify in place
+}
+
+// lastEpoch is the most recently assigned tree epoch.
+var lastEpoch atomic.Uint64
+
+// newEpoch returns a new, unique tree epoch. Its low bits are zero, so that
+// nodes can record their layout there.
+func newEpoch() uint64 { return lastEpoch.Add(1 << layoutBits) }
+
+// layout reports the layout of the nodes t allocates.
+func (t *Tree[K, V]) layout() layout {
+	if t.aug != nil {
+		return aggLayout
+	} else if t.ranked {
+		return rankedLayout
+	}
+	return plainLayout
+}
+
+// newNode returns a new node owned by t with the given key and value.
+func (t *Tree[K, V]) newNode(key K, value V) *node[K, V] {
+	l := t.layout()
+	n := node[K, V]{key: key, value: value, epoch: t.epoch | uint64(l)}
+	switch l {
+	case aggLayout:
+		return t.aug.newNode(n)
+	case rankedLayout:
+		r := &rankedNode[K, V]{node: n, count: 1}
+		return &r.node
+	}
+	return &n
+}
+
+// Clone returns a copy of t, in constant time. The copy shares the nodes of t
+// until either tree is modified, after which each tree copies the nodes along
+// the paths it modifies, leaving the other undisturbed. Thus a clone behaves
+// as an independent snapshot of t, and may be safely read by one goroutine
+// while another modifies t.
+func (t *Tree[K, V]) Clone() *Tree[K, V] {
+	// Neither tree may now modify any of the existing nodes in place, so both
+	// get new epochs.
+	cp := *t
+	cp.epoch = newEpoch()
+	t.epoch = newEpoch()
+	if t.pending != nil {
+		// Each tree continues the rebuild in progress on its own.
+		cp.pending = t.pending.clone()
+	}
+	return &cp
+}
+
+func toFraction(β int) float64 { return (float64(β) + maxBalance) / fracLimit }
+
+// limitFunc returns a function that computes the depth limit for a tree of
+// size n given the balance factor β.
+func limitFunc(β int) func(int) int {
+	inv := 1 / toFraction(β)
+	if inv == 1 { // int(+Inf) ⇒ undefined
+		return func(n int) int { return n + 1 }
+	}
+	base := math.Log(inv)
+	return func(n int) int { return int(math.Log(float64(n)) / base) }
+}
+
+// Insert adds key into the tree if it is not already present, and reports
+// whether a new node was added. If t is a multimap, Insert always adds a new
+// node, after any existing nodes with the same key.
+func (t *Tree[K, V]) Insert(key K, value V) bool {
+	// We don't yet know whether the insertion will add mass to the tree; we
+	// conservatively assume it might for purposes of choosing a depth limit.
+	ins, ok := t.insert(key, value, false, t.limit(t.size+1))
+	t.incSize(ok)
+	t.root = ins
+	t.advance(key)
+	return ok
+}
+
+// Replace adds key to the tree, updating an existing key if it is already
+// present. Reports whether a new node was added. If t is a multimap and key
+// is present, Replace updates the value of the first node with that key.
+func (t *Tree[K, V]) Replace(key K, value V) bool {
+	if t.multi {
+		if root, ok := t.replaceFirst(t.root, key, value); ok {
+			t.root = root
+			t.advance(key)
+			return false
+		}
+	}
+	ins, ok := t.insert(key, value, true, t.limit(t.size+1))
+	t.incSize(ok)
+	t.root = ins
+	t.advance(key)
+	return ok
+}
+
+// incSize increments t.size and updates t.max if inserted is true.
+func (t *Tree[K, V]) incSize(inserted bool) {
+	if inserted {
+		t.mods++
+		t.size++
+		if t.size > t.max {
+			t.max = t.size
+		}
+	}
+}
+
+// insert key in order into t, with the given depth limit for the root.
+//
+// If replace is true and an existing node has an equivalent key, it is updated
+// with the given value; otherwise, inserting an existing key is a no-op.
+//
+// Returns the modified tree, and reports whether a new node was added.
+func (t *Tree[K, V]) insert(key K, value V, replace bool, limit int) (*node[K, V], bool) {
+	// Descending phase: Find the path to the point of insertion.
+	var buf [pathBuf]step[K, V]
+	path := buf[:0]
+	for cur := t.root; cur != nil; {
+		c := t.compare(key, cur.key)
+		if c == 0 && !t.multi {
+			// Replacing an existing node. This cannot introduce a violation,
+			// so there is no need for a goat search.
+			if !replace {
+				return t.root, false
+			}
+			cur = t.mut(cur)
+			cur.value = value
+			t.fix(cur)
+			return t.relink(path, cur), false
+		}
+		// In a multimap, an equal key goes after the existing ones.
+		path = append(path, step[K, V]{cur, c < 0})
+		if c < 0 {
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+	n := t.newNode(key, value)
+	t.fix(n)
+	return t.ascend(path, n, limit), true
+}
+
+// ascend implements the ascending phase of an insertion, in which n is a new
+// node added at the end of path, and limit is the depth limit for the root.
+// It links n into the tree, and rebuilds the scapegoat, if the new node is too
+// deep. Returns the modified tree.
+func (t *Tree[K, V]) ascend(path []step[K, V], n *node[K, V], limit int) *node[K, V] {
+	// If the insertion exceeded the depth limit, size is the size of the
+	// subtree below each node of the path, until a goat is found.
+	var size int
+	if len(path) > limit {
+		size = 1
+	}
+	sub := n
+	for i := len(path) - 1; i >= 0; i-- {
+		root := t.mut(path[i].n)
+		var sib *node[K, V]
+		if path[i].left {
+			root.left, sib = sub, root.right
+		} else {
+			root.right, sib = sub, root.left
+		}
+		t.fix(root)
+		sub, size = t.rodeo(root, sib, size, len(path)-i, limit-i)
+	}
+	return sub
+}
+
+// rodeo implements the ascending phase of an insertion, a.k.a., goat rodeo,
+// for a root whose child on the path of insertion has the given size, and
+// whose other child is sib, and whose depth limit is limit. It returns the
+// updated root, and the size to be passed to the step above root.
+// Uses the selection strategy from section 4.6 of Galperin & Rivest .
+func (t *Tree[K, V]) rodeo(root, sib *node[K, V], size, height, limit int) (*node[K, V], int) {
+	// If size != 0, we exceeded the depth limit and are looking for a goat.
+	// Note: size == ins.size() not root.size() at this point.
+	if size == 0 {
+		return root, 0
+	} else if t.quantum < 0 {
+		// Rebuilding is disabled, so the new node stays too deep.
+		t.excess++
+		return root, 0
+	}
+	var chunk int // the largest goat to rebuild now, if deamortized
+	if t.quantum > 0 {
+		chunk = t.chunk(t.size + 1)
+	}
+	var sibSize int // size of sibling subtree
+	if chunk > 0 && !t.ranked {
+		// A goat larger than the chunk is not rebuilt now, so there is no
+		// need to count beyond it.
+		sibSize = sib.sizeUpTo(chunk)
+	} else {
+		sibSize = t.sizeOf(sib)
+	}
+	rootSize := sibSize + 1 + size // new size of root
+
+	if chunk > 0 && rootSize > chunk {
+		// The goat is root or one of its ancestors, and is too large to
+		// rebuild now; defer it, and let the new node stay too deep until the
+		// deferred rebuild is done.
+		t.deferRebuild(byInsert)
+		t.excess++
+		return root, 0
+	} else if bw := t.limit(rootSize); height <= bw {
+		return root, rootSize // not the goat you're looking for; move along
+	}
+	// root is the goat; rewrite it and signal the steps above us to stop
+	// looking by setting size to 0.
+	// Its depth is the amount by which the limit has been decremented.
+	root, _ = t.rebuild(root, rootSize, t.limit(t.size+1)-limit, byInsert)
+	return root, 0
+}
+
+// Remove key from the tree and report whether it was present. If t is a
+// multimap, Remove removes only the first node with that key.
+func (t *Tree[K, V]) Remove(key K) bool {
+	del, ok := t.remove(t.root, key)
+	t.root = del
+	t.decSize(ok)
+	t.advance(key)
+	return ok
+}
+
+// decSize decrements t.size if removed is true, and rebuilds the tree if it
+// has shrunk too far below its maximum size.
+func (t *Tree[K, V]) decSize(removed bool) {
+	if removed {
+		t.mods++
+		t.size--
+		if bw := (t.max*t.β + maxBalance) / fracLimit; t.size < bw {
+			if root, ok := t.rebuild(t.root, t.size, 0, byRemove); ok {
+				t.root, t.max = root, t.size
+			}
+		}
+	}
+}
+
+// remove key from the subtree under n, returning the modified tree reporting
+// whether the mass of the tree was decreased.
+func (t *Tree[K, V]) remove(n *node[K, V], key K) (_ *node[K, V], ok bool) {
+	var buf [pathBuf]step[K, V]
+	path, target := t.pathToFirst(buf[:0], n, key)
+	if target == nil {
+		return n, false // nothing to do
+	}
+	return t.relink(path, t.unlink(target)), true
+}
+
+// unlink removes n from the subtree rooted at n, and returns the modified
+// subtree.
+func (t *Tree[K, V]) unlink(n *node[K, V]) *node[K, V] {
+	if n.left == nil {
+		return n.right
+	} else if n.right == nil {
+		return n.left
+	}
+
+	// At this point we need to remove n, but it has two children.
+	// Do the usual trick.
+	n = t.mut(n)
+	goat := t.popMinRight(n)
+	n.key, n.value = goat.key, goat.value
+	t.fix(n)
+	return n
+}
+
+// fix updates the subtree count of n from its children, if t is ranked, and
+// the aggregate of n, if t is augmented. The caller must own n.
+func (t *Tree[K, V]) fix(n *node[K, V]) {
+	if t.ranked {
+		n.ranked().count = 1 + n.left.weight() + n.right.weight()
+	}
+	if t.aug != nil {
+		t.aug.update(n)
+	}
+}
+
+// sizeOf reports the number of nodes in the subtree rooted at n. This is
+// constant time if t is ranked, and otherwise proportional to the size.
+func (t *Tree[K, V]) sizeOf(n *node[K, V]) int {
+	if t.ranked {
+		return n.weight()
+	}
+	return n.size()
+}
+
+// Len reports the number of elements stored in the tree.
+func (t *Tree[K, V]) Len() int { return t.size }
+
+// Lookup reports whether key is present in the tree, and returns the value
+// associated with that key, or a zero value if the key is not present. If t
+// is a multimap, Lookup returns the value of the first node with that key.
+func (t *Tree[K, V]) Lookup(key K) (v V, ok bool) {
+	cur := t.root
+	for cur != nil {
+		if c := t.compare(key, cur.key); c < 0 {
+			cur = cur.left
+		} else if c > 0 {
+			cur = cur.right
+		} else {
+			v, ok = cur.value, true
+			if !t.multi {
+				return
+			}
+			cur = cur.left // look for an earlier node with the same key
+		}
+	}
+	return
+}
+
+// Floor returns the key/value pair in t with the greatest key less than or
+// equal to key, and reports whether such a key exists.
+func (t *Tree[K, V]) Floor(key K) (KV[K, V], bool) { return t.below(key, true).kv() }
+
+// Predecessor returns the key/value pair in t with the greatest key strictly
+// less than key, and reports whether such a key exists.
+func (t *Tree[K, V]) Predecessor(key K) (KV[K, V], bool) { return t.below(key, false).kv() }
+
+// Ceiling returns the key/value pair in t with the least key greater than or
+// equal to key, and reports whether such a key exists.
+func (t *Tree[K, V]) Ceiling(key K) (KV[K, V], bool) { return t.above(key, true).kv() }
+
+// Successor returns the key/value pair in t with the least key strictly
+// greater than key, and reports whether such a key exists.
+func (t *Tree[K, V]) Successor(key K) (KV[K, V], bool) { return t.above(key, false).kv() }
+
+// below returns the node with the greatest key less than key, or equal to key
+// if inclusive is true. It returns nil if there is no such node. Among nodes
+// with equal keys, it returns the last.
+func (t *Tree[K, V]) below(key K, inclusive bool) *node[K, V] {
+	var best *node[K, V]
+	cur := t.root
+	for cur != nil {
+		if c := t.compare(key, cur.key); c > 0 {
+			best, cur = cur, cur.right
+		} else if c == 0 && inclusive {
+			if !t.multi {
+				return cur
+			}
+			best, cur = cur, cur.right // look for a later equal key
+		} else {
+			cur = cur.left
+		}
+	}
+	return best
+}
+
+// above returns the node with the least key greater than key, or equal to key
+// if inclusive is true. It returns nil if there is no such node. Among nodes
+// with equal keys, it returns the first.
+func (t *Tree[K, V]) above(key K, inclusive bool) *node[K, V] {
+	var best *node[K, V]
+	cur := t.root
+	for cur != nil {
+		if c := t.compare(key, cur.key); c < 0 {
+			best, cur = cur, cur.left
+		} else if c == 0 && inclusive {
+			if !t.multi {
+				return cur
+			}
+			best, cur = cur, cur.left // look for an earlier equal key
+		} else {
+			cur = cur.right
+		}
+	}
+	return best
+}
+
+// Inorder traverses t inorder and invokes f for each key until either f
+// returns false or no further keys are available.
+func (t *Tree[K, V]) Inorder(f func(KV[K, V]) bool) { t.root.inorder(f) }
+
+// InorderAfter traverses t inorder, considering only keys equal to or after
+// key, and invokes f for each key until either f returns false or no further
+// keys are available.
+func (t *Tree[K, V]) InorderAfter(key K, f func(KV[K, V]) bool) {
+	t.root.inorderAfter(key, t.compare, t.tie(-1), f)
+}
+
+// InorderBefore traverses t in reverse order, considering only keys equal to
+// or before key, and invokes f for each key until either f returns false or
+// no further keys are available.
+func (t *Tree[K, V]) InorderBefore(key K, f func(KV[K, V]) bool) {
+	t.root.inorderBefore(key, t.compare, t.tie(1), f)
+}
+
+// ReverseInorder traverses t in reverse order and invokes f for each key until
+// either f returns false or no further keys are available.
+func (t *Tree[K, V]) ReverseInorder(f func(KV[K, V]) bool) { t.root.reverseInorder(f) }
+
+// Bounds specify which endpoints of a key range are included in the range.
+type Bounds int
+
+const (
+	IncludeLo Bounds = 1 << iota // the lower bound is included
+	IncludeHi                    // the upper bound is included
+
+	Open     Bounds = 0                     // lo < key < hi
+	HalfOpen        = IncludeLo             // lo ≤ key < hi
+	Closed          = IncludeLo | IncludeHi // lo ≤ key ≤ hi
+)
+
+// InorderRange traverses t inorder, considering only keys between lo and hi,
+// and invokes f for each key until either f returns false or no further keys
+// are available. The bounds b specify whether lo and hi are themselves
+// included in the range.
+func (t *Tree[K, V]) InorderRange(lo, hi K, b Bounds, f func(KV[K, V]) bool) {
+	t.root.inorderAfter(lo, t.compare, t.tie(-1), func(kv KV[K, V]) bool {
+		if b&IncludeLo == 0 && t.compare(kv.Key, lo) == 0 {
+			return true // skip the excluded lower bound
+		} else if c := t.compare(kv.Key, hi); c > 0 || (c == 0 && b&IncludeHi == 0) {
+			return false // past the upper bound
+		}
+		return f(kv)
+	})
+}
+
+// Min returns the key/value pair in the tree with the minimum key, or nil if
+// the tree is empty.
+func (t *Tree[K, V]) Min() *KV[K, V] {
+	if t.root == nil {
+		return nil
+	}
+	cur := t.root
+	for cur.left != nil {
+		cur = cur.left
+	}
+	return &KV[K, V]{Key: cur.key, Value: cur.value}
+}
+
+// Max returns the key/value pair in the tree with the maximum key, or nil if
+// the tree is empty.
+func (t *Tree[K, V]) Max() *KV[K, V] {
+	if t.root == nil {
+		return nil
+	}
+	cur := t.root
+	for cur.right != nil {
+		cur = cur.right
+	}
+	return &KV[K, V]{Key: cur.key, Value: cur.value}
+}
+
+// PopMin removes the key/value pair with the minimum key from the tree and
+// returns it, reporting whether the tree was non-empty. If t is a multimap,
+// PopMin removes the first of the nodes with the minimum key.
+func (t *Tree[K, V]) PopMin() (KV[K, V], bool) {
+	if t.root == nil {
+		return KV[K, V]{}, false
+	}
+	root, min := t.popMin(t.root)
+	t.root = root
+	t.decSize(true)
+	t.advance(min.key)
+	return KV[K, V]{Key: min.key, Value: min.value}, true
+}
+
+// PopMax removes the key/value pair with the maximum key from the tree and
+// returns it, reporting whether the tree was non-empty. If t is a multimap,
+// PopMax removes the last of the nodes with the maximum key.
+func (t *Tree[K, V]) PopMax() (KV[K, V], bool) {
+	if t.root == nil {
+		return KV[K, V]{}, false
+	}
+	root, max := t.popMax(t.root)
+	t.root = root
+	t.decSize(true)
+	t.advance(max.key)
+	return KV[K, V]{Key: max.key, Value: max.value}, true
+}
+
+// PopMinN removes up to n key/value pairs with the smallest keys from the tree
+// and returns them in order. If the tree has fewer than n elements, PopMinN
+// removes all of them.
+//
+// If n is large relative to the size of t, PopMinN rebuilds the tree from the
+// remaining nodes once, in O(size) time, rather than removing the pairs one at
+// a time.
+func (t *Tree[K, V]) PopMinN(n int) []KV[K, V] {
+	n = min(n, t.size)
+	if n <= 0 {
+		return nil
+	}
+	out := make([]KV[K, V], 0, n)
+	if !t.mergeBatch(n) {
+		for len(out) < n {
+			kv, _ := t.PopMin()
+			out = append(out, kv)
+		}
+		return out
+	}
+	rest := make([]*node[K, V], 0, t.size-n)
+	t.root.walk(func(nd *node[K, V]) {
+		if len(out) < n {
+			out = append(out, KV[K, V]{Key: nd.key, Value: nd.value})
+		} else {
+			rest = append(rest, t.mut(nd))
+		}
+	})
+	t.rebuildFrom(rest)
+	return out
+}
@@ -0,0 +1,636 @@
+package generic
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"testing"
+
+	"bitbucket.org/creachadair/stringset"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var (
+	strictness = flag.Int("balance", 100, "Balancing factor")
+	dotFile    = flag.String("dot", "", "Emit DOT output to this file")
+	sortWords  = flag.Bool("sort", false, "Sort input words before insertion")
+)
+
+// Construct a tree with the words from input, returning the finished tree and
+// the original words as split by strings.Fields.
+func makeTree(β int, input string) (*Tree[string, int], []string) {
+	tree := New[string, int](β)
+	words := strings.Fields(input)
+	if *sortWords {
+		sort.Strings(words)
+	}
+	for i, w := range words {
+		tree.Insert(w, i+1)
+	}
+	return tree, words
+}
+
+// Export all the keys in tree in their stored order.
+func allKeys[K, V any](tree *Tree[K, V]) []K {
+	var got []K
+	tree.Inorder(func(kv KV[K, V]) bool {
+		got = append(got, kv.Key)
+		return true
+	})
+	return got
+}
+
+// checkTree verifies that tree is correctly ordered, that its cached size
+// agrees with its contents, and that its subtree counts are correct if it is
+// ranked.
+func checkTree[K, V any](t *testing.T, tree *Tree[K, V]) {
+	t.Helper()
+	if err := tree.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	keys := allKeys(tree)
+	for i := 1; i < len(keys); i++ {
+		if !inOrder(tree.compare(keys[i-1], keys[i]), tree.multi) {
+			t.Errorf("Keys out of order at %d: %v ≥ %v", i, keys[i-1], keys[i])
+		}
+	}
+	if n := tree.root.size(); n != tree.Len() {
+		t.Errorf("Tree has %d nodes, but Len is %d", n, tree.Len())
+	} else if tree.max < n {
+		t.Errorf("Tree has %d nodes, but max is %d", n, tree.max)
+	}
+	if tree.ranked {
+		checkCounts(t, tree.root)
+	}
+}
+
+// If an output file is specified, dump a DOT graph of tree.
+func dumpTree[K, V any](tree *Tree[K, V]) {
+	if *dotFile == "" {
+		return
+	}
+	f, err := os.Create(*dotFile)
+	if err != nil {
+		log.Fatalf("Unable to create DOT output: %v", err)
+	}
+	dotTree(f, tree.root)
+	if err := f.Close(); err != nil {
+		log.Fatalf("Unable to close output: %v", err)
+	}
+}
+
+// Render tree to a GraphViz graph.
+func dotTree[K, V any](w io.Writer, root *node[K, V]) {
+	fmt.Fprintln(w, "digraph Tree {")
+
+	i := 0
+	next := func() int {
+		i++
+		return i
+	}
+
+	var ptree func(*node[K, V]) int
+	ptree = func(root *node[K, V]) int {
+		if root == nil {
+			return 0
+		}
+		id := next()
+		fmt.Fprintf(w, "\tN%04d [label=\"%v\"]\n", id, root.key)
+		if lc := ptree(root.left); lc != 0 {
+			fmt.Fprintf(w, "\tN%04d -> N%04d\n", id, lc)
+		}
+		if rc := ptree(root.right); rc != 0 {
+			fmt.Fprintf(w, "\tN%04d -> N%04d\n", id, rc)
+		}
+		return id
+	}
+	ptree(root)
+	fmt.Fprintln(w, "}")
+}
+
+func TestNew(t *testing.T) {
+	tree := New(200,
+		KV[string, bool]{Key: "please"},
+		KV[string, bool]{Key: "fetch"},
+		KV[string, bool]{Key: "your"},
+		KV[string, bool]{Key: "slippers"},
+	)
+	got := allKeys(tree)
+	want := []string{"fetch", "please", "slippers", "your"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("New produced unexpected output (-got, +want)\n%s", diff)
+	}
+}
+
+func TestNewDuplicates(t *testing.T) {
+	tree := New(0,
+		KV[int, string]{Key: 3, Value: "a"},
+		KV[int, string]{Key: 1, Value: "b"},
+		KV[int, string]{Key: 3, Value: "c"},
+		KV[int, string]{Key: 2, Value: "d"},
+		KV[int, string]{Key: 1, Value: "e"},
+		KV[int, string]{Key: 3, Value: "f"},
+	)
+	checkTree(t, tree)
+	want := []KV[int, string]{{1, "e"}, {2, "d"}, {3, "f"}}
+	if diff := cmp.Diff(want, contents(tree)); diff != "" {
+		t.Errorf("New with duplicates (-want, +got)\n%s", diff)
+	}
+	if tree.Len() != 3 {
+		t.Errorf("Len: got %d, want 3", tree.Len())
+	}
+}
+
+// sliceSeq returns an iterator over kvs.
+func sliceSeq[K, V any](kvs []KV[K, V]) func(func(KV[K, V]) bool) {
+	return func(yield func(KV[K, V]) bool) {
+		for _, kv := range kvs {
+			if !yield(kv) {
+				return
+			}
+		}
+	}
+}
+
+func TestNewFromSorted(t *testing.T) {
+	var kvs []KV[int, int]
+	for i := 0; i < 1000; i++ {
+		kvs = append(kvs, KV[int, int]{Key: 2 * i, Value: i})
+	}
+	tree, err := NewFromSorted(100, sliceSeq(kvs))
+	if err != nil {
+		t.Fatalf("NewFromSorted failed: %v", err)
+	}
+	checkTree(t, tree)
+	if diff := cmp.Diff(kvs, contents(tree)); diff != "" {
+		t.Errorf("NewFromSorted (-want, +got)\n%s", diff)
+	}
+	if h, max := tree.root.height(), 10; h > max {
+		t.Errorf("Height: got %d, want ≤ %d", h, max)
+	}
+	tree.Insert(1, -1)
+	checkTree(t, tree)
+
+	// A channel can be consumed as a sequence.
+	ch := make(chan KV[int, int], 3)
+	ch <- KV[int, int]{Key: 1}
+	ch <- KV[int, int]{Key: 2}
+	ch <- KV[int, int]{Key: 3}
+	close(ch)
+	tree, err = NewFromSorted(0, func(yield func(KV[int, int]) bool) {
+		for kv := range ch {
+			if !yield(kv) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("NewFromSorted (channel) failed: %v", err)
+	} else if got := allKeys(tree); !cmp.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("NewFromSorted (channel): got %v, want [1 2 3]", got)
+	}
+
+	// An empty sequence produces an empty tree.
+	if tree, err := NewFromSorted(0, sliceSeq[int, int](nil)); err != nil {
+		t.Errorf("NewFromSorted (empty) failed: %v", err)
+	} else if tree.Len() != 0 {
+		t.Errorf("NewFromSorted (empty): Len = %d, want 0", tree.Len())
+	}
+}
+
+func TestNewFromSortedErrors(t *testing.T) {
+	tests := []struct {
+		keys    []string
+		consume int
+		want    string
+	}{
+		{[]string{"a", "c", "b", "d"}, 3, "out of order"},
+		{[]string{"a", "b", "b", "c"}, 3, "duplicate key"},
+	}
+	for _, test := range tests {
+		consumed := 0
+		_, err := NewFromSorted(0, func(yield func(KV[string, int]) bool) {
+			for _, key := range test.keys {
+				consumed++
+				if !yield(KV[string, int]{Key: key}) {
+					return
+				}
+			}
+		})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("NewFromSorted(%q): got error %v, want %q", test.keys, err, test.want)
+		}
+		if consumed != test.consume {
+			t.Errorf("NewFromSorted(%q): consumed %d entries, want %d", test.keys, consumed, test.consume)
+		}
+	}
+}
+
+func TestNewFunc(t *testing.T) {
+	// Order integers by descending absolute value.
+	tree := NewFunc(50, func(a, b int) int {
+		if a < 0 {
+			a = -a
+		}
+		if b < 0 {
+			b = -b
+		}
+		return b - a
+	}, KV[int, string]{Key: 3, Value: "c"}, KV[int, string]{Key: -5, Value: "e"})
+	for _, k := range []int{-1, 4, 2, -6} {
+		tree.Insert(k, "")
+	}
+	if tree.Insert(-4, "dup") {
+		t.Error("Insert(-4) reported a new node, but 4 is equivalent")
+	}
+	if v, ok := tree.Lookup(-3); !ok || v != "c" {
+		t.Errorf("Lookup(-3): got (%q, %v), want (c, true)", v, ok)
+	}
+
+	got := allKeys(tree)
+	want := []int{-6, -5, 4, 3, 2, -1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Inorder produced unexpected output (-want, +got)\n%s", diff)
+	}
+}
+
+func TestBasicProperties(t *testing.T) {
+	// http://www.gutenberg.org/files/1063/1063-h/1063-h.htm
+	text, err := os.ReadFile("../cask.txt")
+	if err != nil {
+		t.Fatalf("Reading text: %v", err)
+	}
+	tree, words := makeTree(*strictness, string(text))
+	t.Logf("Final tree has size %d; height %d", tree.Len(), tree.root.height())
+	dumpTree(tree)
+
+	got := allKeys(tree)
+	want := stringset.New(words...).Elements()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Inorder produced unexpected output (-want, +got)\n%s", diff)
+	}
+	for _, word := range want {
+		if _, ok := tree.Lookup(word); !ok {
+			t.Errorf("Word %q not found", word)
+		}
+	}
+}
+
+func TestRemoval(t *testing.T) {
+	tree, words := makeTree(0, `a foolish consistency is the hobgoblin of little minds`)
+
+	got := allKeys(tree)
+	if diff := cmp.Diff(stringset.New(words...).Elements(), got); diff != "" {
+		t.Errorf("Original input differs from expected (-want, +got)\n%s", diff)
+	}
+
+	drop := stringset.New("a", "is", "of", "the")
+	for w := range drop {
+		if !tree.Remove(w) {
+			t.Errorf("Remove(%q) returned false, wanted true", w)
+		}
+	}
+
+	got = allKeys(tree)
+	want := stringset.New(words...).Diff(drop).Elements()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Tree after removal is incorrect (-want, +got)\n%s", diff)
+	}
+
+	// Verify that values moved along with their keys.
+	for i, w := range words {
+		if drop.Contains(w) {
+			continue
+		} else if v, ok := tree.Lookup(w); !ok || v != i+1 {
+			t.Errorf("Lookup(%q): got (%v, %v), want (%v, true)", w, v, ok, i+1)
+		}
+	}
+}
+
+func TestInorderAfter(t *testing.T) {
+	tree := New[int, any](0)
+	for _, k := range []int{8, 6, 7, 5, 3, 0, 9} {
+		tree.Insert(k, nil)
+	}
+	tests := []struct {
+		key  int
+		want []int
+	}{
+		{10, nil},
+		{9, []int{9}},
+		{8, []int{8, 9}},
+		{7, []int{7, 8, 9}},
+		{4, []int{5, 6, 7, 8, 9}},
+		{3, []int{3, 5, 6, 7, 8, 9}},
+		{1, []int{3, 5, 6, 7, 8, 9}},
+		{0, []int{0, 3, 5, 6, 7, 8, 9}},
+		{-1, []int{0, 3, 5, 6, 7, 8, 9}},
+	}
+	for _, test := range tests {
+		var got []int
+		tree.InorderAfter(test.key, func(kv KV[int, any]) bool {
+			got = append(got, kv.Key)
+			return true
+		})
+		if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("InorderAfter(%v) result differed from expected\n%s", test.key, diff)
+		}
+	}
+}
+
+func TestInorderBefore(t *testing.T) {
+	tree := New[int, any](0)
+	for _, k := range []int{8, 6, 7, 5, 3, 0, 9} {
+		tree.Insert(k, nil)
+	}
+	tests := []struct {
+		key  int
+		want []int
+	}{
+		{-1, nil},
+		{0, []int{0}},
+		{2, []int{0}},
+		{3, []int{3, 0}},
+		{4, []int{3, 0}},
+		{7, []int{7, 6, 5, 3, 0}},
+		{9, []int{9, 8, 7, 6, 5, 3, 0}},
+		{10, []int{9, 8, 7, 6, 5, 3, 0}},
+	}
+	for _, test := range tests {
+		var got []int
+		tree.InorderBefore(test.key, func(kv KV[int, any]) bool {
+			got = append(got, kv.Key)
+			return true
+		})
+		if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("InorderBefore(%v) result differed from expected\n%s", test.key, diff)
+		}
+	}
+}
+
+func TestReverseInorder(t *testing.T) {
+	tree, words := makeTree(*strictness, `it is a truth universally acknowledged`)
+	want := stringset.New(words...).Elements()
+	sort.Sort(sort.Reverse(sort.StringSlice(want)))
+
+	var got []string
+	tree.ReverseInorder(func(kv KV[string, int]) bool {
+		got = append(got, kv.Key)
+		return true
+	})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReverseInorder produced unexpected output (-want, +got)\n%s", diff)
+	}
+
+	got = nil
+	tree.ReverseInorder(func(kv KV[string, int]) bool {
+		got = append(got, kv.Key)
+		return len(got) < 2
+	})
+	if diff := cmp.Diff(want[:2], got); diff != "" {
+		t.Errorf("ReverseInorder did not stop early (-want, +got)\n%s", diff)
+	}
+}
+
+func TestInorderRange(t *testing.T) {
+	tree := New[int, any](0)
+	for _, k := range []int{8, 6, 7, 5, 3, 0, 9} {
+		tree.Insert(k, nil)
+	}
+	tests := []struct {
+		lo, hi int
+		b      Bounds
+		want   []int
+	}{
+		{0, 9, Closed, []int{0, 3, 5, 6, 7, 8, 9}},
+		{0, 9, Open, []int{3, 5, 6, 7, 8}},
+		{0, 9, HalfOpen, []int{0, 3, 5, 6, 7, 8}},
+		{0, 9, IncludeHi, []int{3, 5, 6, 7, 8, 9}},
+		{1, 6, Closed, []int{3, 5, 6}},
+		{1, 6, HalfOpen, []int{3, 5}},
+		{4, 5, Open, nil},
+		{5, 5, Closed, []int{5}},
+		{5, 5, HalfOpen, nil},
+		{6, 2, Closed, nil},
+		{-5, 2, Open, []int{0}},
+		{9, 20, Closed, []int{9}},
+	}
+	for _, test := range tests {
+		var got []int
+		tree.InorderRange(test.lo, test.hi, test.b, func(kv KV[int, any]) bool {
+			got = append(got, kv.Key)
+			return true
+		})
+		if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("InorderRange(%v, %v, %v) result differed from expected\n%s",
+				test.lo, test.hi, test.b, diff)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	tree := New[int, string](0)
+	for _, k := range []int{8, 6, 7, 5, 3, 0, 9} {
+		tree.Insert(k, fmt.Sprint("v", k))
+	}
+	const none = -100
+	tests := []struct {
+		key                     int
+		floor, pred, ceil, succ int
+	}{
+		{-1, none, none, 0, 0},
+		{0, 0, none, 0, 3},
+		{1, 0, 0, 3, 3},
+		{3, 3, 0, 3, 5},
+		{4, 3, 3, 5, 5},
+		{7, 7, 6, 7, 8},
+		{9, 9, 8, 9, none},
+		{10, 9, 9, none, none},
+	}
+	for _, test := range tests {
+		for _, probe := range []struct {
+			name string
+			find func(int) (KV[int, string], bool)
+			want int
+		}{
+			{"Floor", tree.Floor, test.floor},
+			{"Predecessor", tree.Predecessor, test.pred},
+			{"Ceiling", tree.Ceiling, test.ceil},
+			{"Successor", tree.Successor, test.succ},
+		} {
+			got, ok := probe.find(test.key)
+			if probe.want == none {
+				if ok {
+					t.Errorf("%s(%d): got %+v, want none", probe.name, test.key, got)
+				}
+			} else if !ok || got.Key != probe.want || got.Value != fmt.Sprint("v", probe.want) {
+				t.Errorf("%s(%d): got %+v, %v; want %d", probe.name, test.key, got, ok, probe.want)
+			}
+		}
+	}
+}
+
+func TestPopMinMax(t *testing.T) {
+	for _, ranked := range []bool{false, true} {
+		tree := intTree(100, ranked, intRange(0, 50, 1))
+		sum := Augment(tree, sumValues)
+		snap := tree.Clone()
+
+		lo, hi := 0, 49
+		for i := 0; tree.Len() != 0; i++ {
+			pop, want := tree.PopMin, &lo
+			if i%3 == 0 {
+				pop, want = tree.PopMax, &hi
+			}
+			kv, ok := pop()
+			if !ok || kv.Key != *want || kv.Value != *want {
+				t.Fatalf("Pop %d: got %+v, %v; want %d", i, kv, ok, *want)
+			}
+			if want == &lo {
+				lo++
+			} else {
+				hi--
+			}
+			checkTree(t, tree)
+			checkAggs(t, sum.m, tree.root)
+		}
+		if kv, ok := tree.PopMin(); ok {
+			t.Errorf("PopMin on empty tree: got %+v", kv)
+		}
+		if kv, ok := tree.PopMax(); ok {
+			t.Errorf("PopMax on empty tree: got %+v", kv)
+		}
+		if snap.Len() != 50 {
+			t.Errorf("Snapshot Len = %d, want 50", snap.Len())
+		}
+	}
+
+	// In a multimap, PopMin and PopMax remove the first and last nodes.
+	tree := New[int, int](0)
+	tree.SetMulti(true)
+	for i := 0; i < 6; i++ {
+		tree.Insert(i%2, i)
+	}
+	if kv, _ := tree.PopMin(); kv.Value != 0 {
+		t.Errorf("PopMin: got %+v, want 0=0", kv)
+	}
+	if kv, _ := tree.PopMax(); kv.Value != 5 {
+		t.Errorf("PopMax: got %+v, want 1=5", kv)
+	}
+}
+
+func TestPopMinN(t *testing.T) {
+	// Batch sizes both below and above the rebuild threshold.
+	for _, n := range []int{0, 3, 50, 99, 150} {
+		tree := intTree(100, true, intRange(0, 100, 1))
+		sum := Augment(tree, sumValues)
+		snap := tree.Clone()
+
+		m := min(n, 100)
+		var want []KV[int, int]
+		for _, k := range intRange(0, m, 1) {
+			want = append(want, KV[int, int]{Key: k, Value: k})
+		}
+		if diff := cmp.Diff(want, tree.PopMinN(n)); diff != "" {
+			t.Errorf("PopMinN(%d) (-want, +got)\n%s", n, diff)
+		}
+		checkTree(t, tree)
+		checkAggs(t, sum.m, tree.root)
+		if diff := cmp.Diff(intRange(m, 100, 1), allKeys(tree)); diff != "" {
+			t.Errorf("PopMinN(%d): remaining keys (-want, +got)\n%s", n, diff)
+		}
+		if snap.Len() != 100 {
+			t.Errorf("PopMinN(%d): snapshot Len = %d, want 100", n, snap.Len())
+		}
+	}
+}
+
+func TestDegenerate(t *testing.T) {
+	// With no rebalancing, ordered insertions make the tree a list. Build one
+	// directly, since inserting n keys in order would take O(n²) time.
+	const n = 1_000_000
+	tree := New[int, int](1000)
+	for i := n - 1; i >= 0; i-- {
+		nd := tree.newNode(i, i)
+		nd.right = tree.root
+		tree.root = nd
+	}
+	tree.size, tree.max = n, n
+
+	// Operations that recurse along the paths of the tree would need stack in
+	// proportion to its height, far more than this.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	if err := tree.Check(); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if h := tree.Stats().Height; h != n {
+		t.Fatalf("Height: got %d, want %d", h, n)
+	}
+
+	// Updates at the far end of the list.
+	if !tree.Insert(n, n) || tree.Replace(n, -n) || !tree.Remove(n-1) {
+		t.Error("Insert, Replace, or Remove at the end of the list failed")
+	}
+	tree.Update(n-2, func(v int, ok bool) (int, Action) { return -v, Replace })
+	if v, ok := tree.Lookup(n - 2); !ok || v != 2-n {
+		t.Errorf("Lookup(%d): got %d, %v; want %d", n-2, v, ok, 2-n)
+	}
+	if kv, ok := tree.PopMax(); !ok || kv.Key != n {
+		t.Errorf("PopMax: got %+v, %v; want %d", kv, ok, n)
+	}
+	if nr := tree.RemoveRange(n-10, n-5, Closed); nr != 6 {
+		t.Errorf("RemoveRange: removed %d, want 6", nr)
+	}
+
+	// Traversals in both directions.
+	var count, last int
+	tree.Inorder(func(kv KV[int, int]) bool { count, last = count+1, kv.Key; return true })
+	if count != tree.Len() || last != n-2 {
+		t.Errorf("Inorder: got %d keys ending with %d, want %d ending with %d", count, last, tree.Len(), n-2)
+	}
+	tree.ReverseInorder(func(kv KV[int, int]) bool { last = kv.Key; return true })
+	if last != 0 {
+		t.Errorf("ReverseInorder: ended with %d, want 0", last)
+	}
+
+	// Whole-tree bookkeeping.
+	tree.SetRanked(true)
+	if r := tree.Rank(n - 2); r != tree.Len()-1 {
+		t.Errorf("Rank(%d): got %d, want %d", n-2, r, tree.Len()-1)
+	}
+	sum := Augment(tree, sumValues)
+	// The keys 0..n-2 remain, less the removed range, and n-2 was negated.
+	want := (n-1)*(n-2)/2 - 2*(n-2) - (n-10+n-5)*6/2
+	if got := sum.Total(); got != want {
+		t.Errorf("Total: got %d, want %d", got, want)
+	}
+	if err := tree.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+
+	// Splitting and joining the list.
+	size := tree.Len()
+	left, right := tree.Split(n / 2)
+	if left.Len() != n/2 || right.Len() != size-n/2 {
+		t.Errorf("Split: got %d and %d keys, want %d and %d", left.Len(), right.Len(), n/2, size-n/2)
+	}
+	tree = Join(left, right)
+	if tree.Len() != size {
+		t.Errorf("Join: got %d keys, want %d", tree.Len(), size)
+	}
+
+	tree.SetBalance(0, true)
+	if h, want := tree.Stats().Height, tree.limit(tree.Len())+1; h > want {
+		t.Errorf("After rebalancing: height %d, want ≤ %d", h, want)
+	}
+	if err := tree.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
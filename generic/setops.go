@@ -0,0 +1,89 @@
+package generic
+
+// The set operations in this file treat trees as sets of keys. Each returns a
+// new tree with the same balancing factor and ordering as t, leaving t and u
+// unmodified. Both trees must use the same key ordering. Each operation costs
+// O(m + n) time for trees of sizes m and n, and builds a balanced result.
+// If either tree is a multimap, so is the result; see SetMulti.
+
+// Union returns a new tree containing the keys present in either t or u. If a
+// key is present in both, resolve is called with the key and the two values
+// (from t and u, respectively) to choose the value for the result. If resolve
+// is nil, the value from t is kept.
+func (t *Tree[K, V]) Union(u *Tree[K, V], resolve func(key K, a, b V) V) *Tree[K, V] {
+	return t.combine(u, true, true, func(a, b *node[K, V]) V {
+		if resolve == nil {
+			return a.value
+		}
+		return resolve(a.key, a.value, b.value)
+	})
+}
+
+// Intersect returns a new tree containing the keys present in both t and u,
+// with their values from t.
+func (t *Tree[K, V]) Intersect(u *Tree[K, V]) *Tree[K, V] {
+	return t.combine(u, false, false, func(a, _ *node[K, V]) V { return a.value })
+}
+
+// Difference returns a new tree containing the keys of t that are not present
+// in u.
+func (t *Tree[K, V]) Difference(u *Tree[K, V]) *Tree[K, V] {
+	return t.combine(u, true, false, nil)
+}
+
+// SymmetricDifference returns a new tree containing the keys present in
+// exactly one of t and u, with their values from the tree that contains them.
+func (t *Tree[K, V]) SymmetricDifference(u *Tree[K, V]) *Tree[K, V] {
+	return t.combine(u, true, true, nil)
+}
+
+// combine merges the inorder sequences of t and u into a new tree. Keys that
+// occur only in t are kept if onlyT is true, and keys that occur only in u are
+// kept if onlyU is true. For keys that occur in both, both is called with the
+// nodes from t and u to choose the value to keep; if both == nil, such keys are
+// discarded. The nodes of the result are copies, so t and u are not modified.
+func (t *Tree[K, V]) combine(u *Tree[K, V], onlyT, onlyU bool, both func(a, b *node[K, V]) V) *Tree[K, V] {
+	as := t.root.flatten(make([]*node[K, V], 0, t.size))
+	bs := u.root.flatten(make([]*node[K, V], 0, u.size))
+
+	res := t.empty()
+	res.multi = t.multi || u.multi
+	var out []*node[K, V]
+	keep := func(key K, value V) {
+		out = append(out, res.newNode(key, value))
+	}
+	i, j := 0, 0
+	for i < len(as) && j < len(bs) {
+		if c := t.compare(as[i].key, bs[j].key); c < 0 {
+			if onlyT {
+				keep(as[i].key, as[i].value)
+			}
+			i++
+		} else if c > 0 {
+			if onlyU {
+				keep(bs[j].key, bs[j].value)
+			}
+			j++
+		} else {
+			if both != nil {
+				keep(as[i].key, both(as[i], bs[j]))
+			}
+			i++
+			j++
+		}
+	}
+	if onlyT {
+		for _, n := range as[i:] {
+			keep(n.key, n.value)
+		}
+	}
+	if onlyU {
+		for _, n := range bs[j:] {
+			keep(n.key, n.value)
+		}
+	}
+
+	res.root = res.extract(out)
+	res.size, res.max = len(out), len(out)
+	return res
+}
@@ -0,0 +1,61 @@
+package generic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSetOps(t *testing.T) {
+	mk := func(kvs ...KV[string, int]) *Tree[string, int] { return New(100, kvs...) }
+	a := mk(KV[string, int]{"ant", 1}, KV[string, int]{"bee", 2}, KV[string, int]{"cat", 3}, KV[string, int]{"eel", 4})
+	b := mk(KV[string, int]{"bee", 20}, KV[string, int]{"dog", 30}, KV[string, int]{"eel", 40}, KV[string, int]{"fox", 50})
+	empty := mk()
+
+	tests := []struct {
+		name string
+		tree *Tree[string, int]
+		want []KV[string, int]
+	}{
+		{"Union", a.Union(b, nil), []KV[string, int]{
+			{"ant", 1}, {"bee", 2}, {"cat", 3}, {"dog", 30}, {"eel", 4}, {"fox", 50},
+		}},
+		{"UnionResolve", a.Union(b, func(_ string, x, y int) int { return x + y }), []KV[string, int]{
+			{"ant", 1}, {"bee", 22}, {"cat", 3}, {"dog", 30}, {"eel", 44}, {"fox", 50},
+		}},
+		{"UnionEmpty", empty.Union(b, nil), []KV[string, int]{
+			{"bee", 20}, {"dog", 30}, {"eel", 40}, {"fox", 50},
+		}},
+		{"Intersect", a.Intersect(b), []KV[string, int]{{"bee", 2}, {"eel", 4}}},
+		{"IntersectReverse", b.Intersect(a), []KV[string, int]{{"bee", 20}, {"eel", 40}}},
+		{"IntersectEmpty", a.Intersect(empty), nil},
+		{"Difference", a.Difference(b), []KV[string, int]{{"ant", 1}, {"cat", 3}}},
+		{"DifferenceReverse", b.Difference(a), []KV[string, int]{{"dog", 30}, {"fox", 50}}},
+		{"DifferenceSelf", a.Difference(a), nil},
+		{"SymmetricDifference", a.SymmetricDifference(b), []KV[string, int]{
+			{"ant", 1}, {"cat", 3}, {"dog", 30}, {"fox", 50},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkTree(t, test.tree)
+			var got []KV[string, int]
+			test.tree.Inorder(func(kv KV[string, int]) bool {
+				got = append(got, kv)
+				return true
+			})
+			if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Result (-want, +got)\n%s", diff)
+			}
+		})
+	}
+
+	// The inputs are not modified.
+	if diff := cmp.Diff([]string{"ant", "bee", "cat", "eel"}, allKeys(a)); diff != "" {
+		t.Errorf("Input a was modified (-want, +got)\n%s", diff)
+	}
+	if v, _ := b.Lookup("bee"); v != 20 {
+		t.Errorf("Input b was modified: bee = %d, want 20", v)
+	}
+}
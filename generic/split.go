@@ -0,0 +1,182 @@
+package generic
+
+// Split moves the contents of t into two new trees, left containing all the
+// keys of t less than key, and right containing all the keys of t greater than
+// or equal to key. The new trees have the same balancing factor and ordering
+// as t, and are ranked if t is ranked. Afterward, t is empty.
+//
+// Split reuses the nodes of t rather than copying them. Its cost is O(lg n)
+// if t is ranked; otherwise it is proportional to the size of left. If either
+// result is too small relative to t for its shape to remain balanced, it is
+// rebuilt, as if by a removal.
+func (t *Tree[K, V]) Split(key K) (left, right *Tree[K, V]) {
+	t.completeRebuild()
+	nleft := t.Rank(key)
+	lroot, rroot := t.split(t.root, key)
+	left, right = t.empty(), t.empty()
+	left.root, right.root = lroot, rroot
+	left.epoch, right.epoch = t.epoch, t.epoch // the nodes of t are disjoint
+	left.loose, right.loose = t.loose, t.loose
+	left.setSize(nleft, t.max)
+	right.setSize(t.size-nleft, t.max)
+	for _, h := range []*Tree[K, V]{left, right} {
+		if h.loose != 0 && h.quantum > 0 {
+			h.deferRebuild(byOther) // restore the height bound for β
+		}
+	}
+	t.clear()
+	return left, right
+}
+
+// Join moves the contents of a and b into a new tree, and returns the new
+// tree. The keys of a and b must be disjoint ranges, in the sense that all the
+// keys of one tree must be less than all the keys of the other. The new tree
+// has the same balancing factor and ordering as a, and is ranked if either a
+// or b is ranked. If a is augmented, the result has the monoid of a, and
+// otherwise that of b; see Augment. Afterward, both a and b are empty.
+//
+// If either a or b is a multimap, so is the result, and the greatest key of
+// one tree may equal the least key of the other. Nodes with that key from the
+// tree with the smaller keys come first.
+//
+// Join reuses the nodes of a and b rather than copying them, and takes
+// O(lg n) time unless the resulting tree must be rebuilt to restore balance.
+//
+// Join panics if the keys of a and b overlap.
+func Join[K, V any](a, b *Tree[K, V]) *Tree[K, V] {
+	a.completeRebuild()
+	b.completeRebuild()
+	out := a.empty()
+	out.epoch = a.epoch
+	out.multi = a.multi || b.multi
+	out.ranked = a.ranked || b.ranked
+	if out.aug == nil {
+		out.aug = b.aug
+	}
+	for _, t := range []*Tree[K, V]{a, b} {
+		if t.aug != out.aug || (out.ranked && !t.ranked) {
+			// Copy the nodes of t into the layout of out.
+			t.ranked, t.aug = out.ranked, out.aug
+			t.root = t.relayout(t.root)
+		}
+	}
+	lo, hi := a, b
+	if a.size != 0 && b.size != 0 {
+		amin, bmin := a.Min(), b.Min()
+		if c := a.compare(bmin.Key, amin.Key); c < 0 || (c == 0 && a.compare(a.Max().Key, bmin.Key) > 0) {
+			// If the minima are equal, the tree consisting only of that key
+			// must go first.
+			lo, hi = b, a
+		}
+		if c := a.compare(lo.Max().Key, hi.Min().Key); c > 0 || (c == 0 && !out.multi) {
+			panic("join: overlapping key ranges")
+		}
+	}
+
+	if hi.root == nil {
+		out.root = lo.root
+	} else {
+		// The smallest node of hi becomes the new root, with the contents of lo
+		// to its left and the remainder of hi to its right.
+		rest, mid := out.popMin(hi.root)
+		mid = out.mut(mid)
+		mid.left, mid.right = lo.root, rest
+		out.fix(mid)
+		out.root = mid
+	}
+	out.loose = max(a.loose, b.loose)
+	out.setSize(a.size+b.size, a.max+b.max)
+
+	// If the two halves are too lopsided for the new root to be balanced,
+	// rebuild the whole tree.
+	if n := out.size; n > 2 {
+		lw, rw := lo.size, hi.size-1
+		if rw > lw {
+			lw = rw
+		}
+		if fracLimit*lw > n*(out.β+maxBalance) {
+			// This is done at once even if out is deamortized, so that the
+			// excess of a tree cannot accumulate over repeated joins.
+			if root, ok := out.rebuild(out.root, n, 0, byOther); ok {
+				out.root, out.max = root, n
+			}
+		}
+	}
+	if out.loose != 0 && out.quantum > 0 {
+		out.deferRebuild(byOther)
+	}
+
+	for _, t := range []*Tree[K, V]{a, b} {
+		t.clear()
+	}
+	return out
+}
+
+// empty returns a new empty tree with the same settings as t.
+func (t *Tree[K, V]) empty() *Tree[K, V] {
+	return &Tree[K, V]{
+		β:       t.β,
+		compare: t.compare,
+		limit:   t.limit,
+		ranked:  t.ranked,
+		aug:     t.aug,
+		multi:   t.multi,
+		hook:    t.hook,
+		quantum: t.quantum,
+		epoch:   newEpoch(),
+	}
+}
+
+// clear empties t after its nodes have been moved to another tree.
+func (t *Tree[K, V]) clear() {
+	t.root = nil
+	t.size, t.max, t.excess, t.loose = 0, 0, 0, 0
+	t.pending = nil
+	t.epoch = newEpoch()
+	t.mods++
+}
+
+// setSize sets the size of t to size, and its high-water mark to max, then
+// rebuilds t if it has shrunk too far below max to remain balanced.
+func (t *Tree[K, V]) setSize(size, max int) {
+	t.size, t.max = size, max
+	if t.max < t.size {
+		t.max = t.size
+	}
+	if bw := (t.max*t.β + maxBalance) / fracLimit; t.size < bw {
+		if root, ok := t.rebuild(t.root, t.size, 0, byRemove); ok {
+			t.root, t.max = root, t.size
+		}
+	}
+}
+
+// split partitions the subtree rooted at n into the nodes with keys less than
+// key and those with keys greater than or equal to key, and returns the roots
+// of the two partitions.
+func (t *Tree[K, V]) split(n *node[K, V], key K) (lo, hi *node[K, V]) {
+	// The nodes on the path to key fall into two chains: those less than key,
+	// linked by their right children, and the rest, linked by their left.
+	var lbuf, hbuf [pathBuf]*node[K, V]
+	los, his := lbuf[:0], hbuf[:0]
+	for n != nil {
+		n = t.mut(n)
+		if t.compare(n.key, key) < 0 {
+			los = append(los, n)
+			n = n.right
+		} else {
+			his = append(his, n)
+			n = n.left
+		}
+	}
+	for i := len(los) - 1; i >= 0; i-- {
+		los[i].right = lo
+		t.fix(los[i])
+		lo = los[i]
+	}
+	for i := len(his) - 1; i >= 0; i-- {
+		his[i].left = hi
+		t.fix(his[i])
+		hi = his[i]
+	}
+	return lo, hi
+}
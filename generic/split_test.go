@@ -0,0 +1,106 @@
+package generic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// intRange returns the integers lo, lo+step, ... less than hi.
+func intRange(lo, hi, step int) []int {
+	var out []int
+	for i := lo; i < hi; i += step {
+		out = append(out, i)
+	}
+	return out
+}
+
+// intTree constructs a tree with the given keys, inserted in order.
+func intTree(β int, ranked bool, keys []int) *Tree[int, int] {
+	tree := New[int, int](β)
+	tree.SetRanked(ranked)
+	for _, k := range keys {
+		tree.Insert(k, k)
+	}
+	return tree
+}
+
+func TestSplit(t *testing.T) {
+	keys := intRange(0, 200, 2)
+	for _, β := range []int{0, 250, 1000} {
+		for _, ranked := range []bool{false, true} {
+			for _, key := range []int{-1, 0, 1, 50, 51, 121, 198, 199, 500} {
+				name := fmt.Sprintf("β=%d/ranked=%v/key=%d", β, ranked, key)
+				t.Run(name, func(t *testing.T) {
+					tree := intTree(β, ranked, keys)
+					left, right := tree.Split(key)
+					if tree.Len() != 0 || tree.root != nil {
+						t.Errorf("Original tree is not empty: %d nodes", tree.Len())
+					}
+					checkTree(t, left)
+					checkTree(t, right)
+
+					var wantLeft, wantRight []int
+					for _, k := range keys {
+						if k < key {
+							wantLeft = append(wantLeft, k)
+						} else {
+							wantRight = append(wantRight, k)
+						}
+					}
+					if diff := cmp.Diff(wantLeft, allKeys(left), cmpopts.EquateEmpty()); diff != "" {
+						t.Errorf("Left keys (-want, +got)\n%s", diff)
+					}
+					if diff := cmp.Diff(wantRight, allKeys(right), cmpopts.EquateEmpty()); diff != "" {
+						t.Errorf("Right keys (-want, +got)\n%s", diff)
+					}
+
+					// Joining the pieces in either order restores the original.
+					joined := Join(right, left)
+					checkTree(t, joined)
+					if diff := cmp.Diff(keys, allKeys(joined)); diff != "" {
+						t.Errorf("Joined keys (-want, +got)\n%s", diff)
+					}
+					if left.Len() != 0 || right.Len() != 0 {
+						t.Errorf("Join inputs not empty: %d, %d", left.Len(), right.Len())
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	small := intTree(0, false, intRange(1000, 1003, 1))
+	big := intTree(0, true, intRange(0, 500, 1))
+	joined := Join(small, big)
+	checkTree(t, joined)
+	if !joined.ranked {
+		t.Error("Joined tree is not ranked")
+	}
+	if got, want := joined.Len(), 503; got != want {
+		t.Errorf("Joined Len: got %d, want %d", got, want)
+	}
+	if got := joined.Select(500).Key; got != 1000 {
+		t.Errorf("Joined Select(500): got %d, want 1000", got)
+	}
+
+	// The result remains usable.
+	joined.Insert(700, 0)
+	joined.Remove(3)
+	checkTree(t, joined)
+
+	empty := New[int, int](0)
+	if got := Join(empty, joined); got.Len() != 503 {
+		t.Errorf("Join with empty: got Len %d, want 503", got.Len())
+	}
+
+	defer func() {
+		if x := recover(); x == nil {
+			t.Error("Join of overlapping trees did not panic")
+		}
+	}()
+	Join(intTree(0, false, []int{1, 5}), intTree(0, false, []int{3}))
+}
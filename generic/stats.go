@@ -0,0 +1,99 @@
+package generic
+
+import "time"
+
+// Stats records the shape of a tree and the rebuilding work it has done.
+// The rebuild counters are cumulative over the lifetime of the tree, and are
+// copied by Clone. Trees produced by Split, Join, and the set operations
+// start with zero counters.
+type Stats struct {
+	Size   int // the number of nodes in the tree
+	Max    int // the greatest size of the tree since it was last rebuilt
+	Height int // the number of nodes on the longest path from the root
+
+	InsertRebuilds int // subtree rebuilds triggered by insertions
+	RemoveRebuilds int // whole-tree rebuilds triggered by removals
+
+	// The number of nodes moved by all rebuilds, and by the largest single
+	// rebuild. These include the rebuilds counted above, as well as rebuilds
+	// done by Join, by Rebalance, and by the batch operations. An incremental
+	// rebuild counts once, when it completes.
+	NodesRebuilt   int
+	LargestRebuild int
+
+	// Excess is the number of levels by which the height of the tree may
+	// exceed the usual bound for its balancing factor, if it is deamortized
+	// or its factor was tightened by SetBalance. Rebuilding reports whether an
+	// incremental rebuild is pending. See SetRebuildQuantum.
+	Excess     int
+	Rebuilding bool
+}
+
+// Stats returns statistics about the shape of t and its rebuilds. Computing
+// the height of t takes O(n) time.
+func (t *Tree[K, V]) Stats() Stats {
+	return Stats{
+		Size:           t.size,
+		Max:            t.max,
+		Height:         t.root.height(),
+		InsertRebuilds: t.stats.inserts,
+		RemoveRebuilds: t.stats.removes,
+		NodesRebuilt:   t.stats.nodes,
+		LargestRebuild: t.stats.largest,
+		Excess:         t.excess + t.loose,
+		Rebuilding:     t.pending != nil,
+	}
+}
+
+// rebuildStats records the rebuilds of a tree, for Stats.
+type rebuildStats struct {
+	inserts, removes int // rebuilds triggered by insertion and removal
+	nodes, largest   int // total and largest number of nodes rebuilt
+}
+
+// A RebuildEvent describes a rebuild of a subtree, for a hook registered with
+// OnRebuild.
+type RebuildEvent struct {
+	Size     int           // the number of nodes rebuilt
+	Depth    int           // the depth of the rebuilt subtree; 0 is the root
+	Duration time.Duration // the time taken to rebuild
+}
+
+// OnRebuild registers f to be called after each rebuild of t or of any of its
+// subtrees, replacing any previous hook. If f == nil, the hook is removed.
+// This includes the rebuilds done by Join and by the batch operations.
+//
+// A rebuild costs time proportional to the size of the subtree, so the hook
+// can be used to log or alert on large rebuilds. Timing is only measured if a
+// hook is registered. The hook is called synchronously, by the method that
+// triggered the rebuild, and must not access t. Trees derived from t by Clone,
+// Split, Join, and the set operations share its hook.
+func (t *Tree[K, V]) OnRebuild(f func(RebuildEvent)) { t.hook = f }
+
+// rebuildStart returns the start time for a rebuild, if t has a hook.
+func (t *Tree[K, V]) rebuildStart() time.Time {
+	if t.hook == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// rebuilt records a rebuild of size nodes at the given depth that began at
+// start, and calls the hook of t, if any.
+func (t *Tree[K, V]) rebuilt(size, depth int, start time.Time) {
+	var d time.Duration
+	if t.hook != nil {
+		d = time.Since(start)
+	}
+	t.report(size, depth, d)
+}
+
+// report records a rebuild of size nodes at the given depth that took time d,
+// and calls the hook of t, if any.
+func (t *Tree[K, V]) report(size, depth int, d time.Duration) {
+	t.stats.nodes += size
+	t.stats.largest = max(t.stats.largest, size)
+	if t.hook != nil {
+		t.hook(RebuildEvent{Size: size, Depth: depth, Duration: d})
+	}
+}
@@ -0,0 +1,93 @@
+package generic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStats(t *testing.T) {
+	// A tree with no rebalancing never rebuilds.
+	loose := intTree(1000, false, intRange(0, 100, 1))
+	if got, want := loose.Stats(), (Stats{Size: 100, Max: 100, Height: 100}); got != want {
+		t.Errorf("Stats (β=1000): got %+v, want %+v", got, want)
+	}
+
+	tree := intTree(500, false, intRange(0, 1000, 1))
+	s := tree.Stats()
+	t.Logf("After insertions: %+v", s)
+	if s.Size != 1000 || s.Max != 1000 {
+		t.Errorf("Stats: got size %d, max %d; want 1000, 1000", s.Size, s.Max)
+	}
+	if s.Height != tree.root.height() || s.Height > tree.limit(s.Max)+1 {
+		t.Errorf("Stats: got height %d, want %d", s.Height, tree.root.height())
+	}
+	if s.InsertRebuilds == 0 || s.RemoveRebuilds != 0 {
+		t.Errorf("Stats: got %d insert and %d remove rebuilds, want > 0 and 0", s.InsertRebuilds, s.RemoveRebuilds)
+	}
+	if s.LargestRebuild == 0 || s.LargestRebuild > s.NodesRebuilt {
+		t.Errorf("Stats: largest rebuild %d, total %d", s.LargestRebuild, s.NodesRebuilt)
+	}
+
+	snap := tree.Clone()
+	for _, k := range intRange(0, 800, 1) {
+		tree.Remove(k)
+	}
+	r := tree.Stats()
+	t.Logf("After removals: %+v", r)
+	if r.Size != 200 || r.RemoveRebuilds != 1 || r.InsertRebuilds != s.InsertRebuilds {
+		t.Errorf("Stats: got size %d with %d insert and %d remove rebuilds, want 200, %d, 1",
+			r.Size, r.InsertRebuilds, r.RemoveRebuilds, s.InsertRebuilds)
+	}
+	if r.NodesRebuilt <= s.NodesRebuilt || r.Max >= s.Max {
+		t.Errorf("Stats: removal rebuild not recorded: %+v", r)
+	}
+	if got := snap.Stats(); got != s {
+		t.Errorf("Clone stats: got %+v, want %+v", got, s)
+	}
+}
+
+func TestOnRebuild(t *testing.T) {
+	tree := New[int, int](0)
+	var events []RebuildEvent
+	tree.OnRebuild(func(e RebuildEvent) {
+		if e.Duration < 0 {
+			t.Errorf("Rebuild has negative duration: %+v", e)
+		}
+		e.Duration = 0
+		events = append(events, e)
+	})
+
+	// Inserting 3 rebuilds the whole tree; inserting 5 rebuilds the subtree
+	// rooted at 3, one level down.
+	for _, k := range intRange(1, 6, 1) {
+		tree.Insert(k, k)
+	}
+	want := []RebuildEvent{{Size: 3, Depth: 0}, {Size: 3, Depth: 1}}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("Rebuild events (-want, +got)\n%s", diff)
+	}
+
+	// Derived trees share the hook, and every rebuild is reported.
+	events = nil
+	left, right := tree.Split(3)
+	for _, k := range intRange(10, 500, 1) {
+		right.Insert(k, k)
+	}
+	nodes := 0
+	for _, e := range events {
+		nodes += e.Size
+	}
+	if s := right.Stats(); len(events) != s.InsertRebuilds+s.RemoveRebuilds || nodes != s.NodesRebuilt {
+		t.Errorf("Got %d rebuilds of %d nodes, but Stats reports %+v", len(events), nodes, s)
+	}
+
+	events = nil
+	left.OnRebuild(nil)
+	for _, k := range intRange(-500, 0, 1) {
+		left.Insert(k, k)
+	}
+	if len(events) != 0 {
+		t.Errorf("Got %d rebuild events after removing the hook", len(events))
+	}
+}
@@ -0,0 +1,113 @@
+package generic
+
+// An Action tells Update how to modify the tree.
+type Action int
+
+const (
+	Keep    Action = iota // leave the tree unchanged
+	Replace               // store the new value, adding a node if necessary
+	Delete                // remove the node for the key, if any
+)
+
+// Update looks up key in t and calls f with its value, or with a zero value
+// if key is not present, and reports whether it was found. The action
+// returned by f determines what happens next: Keep leaves t unchanged;
+// Replace stores the value returned by f for key, adding a new node if key was
+// not present; and Delete removes key from t, if it was present.
+//
+// Update visits the path to key only once, so a read-modify-write such as
+// incrementing a counter costs a single descent rather than a Lookup followed
+// by a Replace. If t is a multimap, Update acts on the first node with key.
+// The function f must not modify t.
+func (t *Tree[K, V]) Update(key K, f func(old V, found bool) (V, Action)) {
+	// As in Insert, we conservatively assume the update might add a node for
+	// purposes of choosing a depth limit.
+	upd, res := t.update(key, f, t.limit(t.size+1))
+	t.root = upd
+	t.incSize(res == updateAdded)
+	t.decSize(res == updateRemoved)
+	t.advance(key)
+}
+
+// Upsert stores the value returned by f for key in t, adding a new node if key
+// is not present. The function f is called with the existing value for key,
+// if any, and reports whether key was found. See Update.
+func (t *Tree[K, V]) Upsert(key K, f func(old V, found bool) V) {
+	t.Update(key, func(old V, found bool) (V, Action) {
+		return f(old, found), Replace
+	})
+}
+
+// GetOrInsert returns the value for key in t and true, if key is present.
+// Otherwise, GetOrInsert adds key to t with the given value, and returns value
+// and false.
+func (t *Tree[K, V]) GetOrInsert(key K, value V) (V, bool) {
+	var found bool
+	t.Update(key, func(old V, ok bool) (V, Action) {
+		if ok {
+			value, found = old, true
+			return old, Keep
+		}
+		return value, Replace
+	})
+	return value, found
+}
+
+// Swap stores value for key in t, adding a new node if key is not present,
+// and returns the previous value for key and whether key was found. If key was
+// not found, old is the zero value.
+func (t *Tree[K, V]) Swap(key K, value V) (old V, found bool) {
+	t.Update(key, func(v V, ok bool) (V, Action) {
+		old, found = v, ok
+		return value, Replace
+	})
+	return
+}
+
+// An updateResult records the effect of an update on the tree.
+type updateResult int
+
+const (
+	updateNone    updateResult = iota // the tree is unchanged
+	updateChanged                     // a value was replaced
+	updateAdded                       // a node was added
+	updateRemoved                     // a node was removed
+)
+
+// update applies f to key in t, with the given depth limit for the root. If t
+// is a multimap, f applies to the first node with key. Returns the modified
+// tree and the effect of the update.
+func (t *Tree[K, V]) update(key K, f func(V, bool) (V, Action), limit int) (*node[K, V], updateResult) {
+	var buf [pathBuf]step[K, V]
+	path, target := t.pathToFirst(buf[:0], t.root, key)
+	if target != nil {
+		n, res := t.updateAt(target, f)
+		if res == updateNone {
+			return t.root, res
+		}
+		return t.relink(path, n), res
+	}
+	var zero V
+	value, act := f(zero, false)
+	if act != Replace {
+		return t.root, updateNone
+	}
+	n := t.newNode(key, value)
+	t.fix(n)
+	return t.ascend(path, n, limit), updateAdded
+}
+
+// updateAt applies f to the value of n, returning the modified subtree and
+// the effect of the update.
+func (t *Tree[K, V]) updateAt(n *node[K, V], f func(V, bool) (V, Action)) (*node[K, V], updateResult) {
+	switch value, act := f(n.value, true); act {
+	case Replace:
+		n = t.mut(n)
+		n.value = value
+		t.fix(n)
+		return n, updateChanged
+	case Delete:
+		return t.unlink(n), updateRemoved
+	}
+	return n, updateNone
+}
@@ -0,0 +1,140 @@
+package generic
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUpdate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, β := range []int{0, 300} {
+		for _, ranked := range []bool{false, true} {
+			tree := intTree(β, ranked, intRange(0, 100, 1))
+			sum := Augment(tree, sumValues)
+			snap := tree.Clone()
+			want := make(map[int]int)
+			for _, k := range intRange(0, 100, 1) {
+				want[k] = k
+			}
+
+			for i := 0; i < 2000; i++ {
+				key, act := rng.Intn(200), Action(rng.Intn(3))
+				old, ok := want[key]
+				tree.Update(key, func(v int, found bool) (int, Action) {
+					if found != ok || v != old {
+						t.Errorf("Update(%d): got (%d, %v), want (%d, %v)", key, v, found, old, ok)
+					}
+					return v + 1, act
+				})
+				switch act {
+				case Replace:
+					want[key] = old + 1
+				case Delete:
+					delete(want, key)
+				}
+			}
+			checkTree(t, tree)
+			checkAggs(t, sum.m, tree.root)
+			if diff := cmp.Diff(mapContents(want), contents(tree)); diff != "" {
+				t.Errorf("β=%d: contents (-want, +got)\n%s", β, diff)
+			}
+			if diff := cmp.Diff(intRange(0, 100, 1), allKeys(snap)); diff != "" {
+				t.Errorf("β=%d: snapshot changed (-want, +got)\n%s", β, diff)
+			}
+		}
+	}
+}
+
+func TestUpdateMulti(t *testing.T) {
+	tree := New[int, int](0)
+	tree.SetMulti(true)
+	var model multiModel
+	for i := 0; i < 30; i++ {
+		tree.Insert(i%6, i)
+		model.insert(i%6, i)
+	}
+
+	// Update acts on the first node with the key.
+	for _, key := range []int{0, 3, 5} {
+		tree.Update(key, func(v int, found bool) (int, Action) {
+			if want := model.values(key)[0]; !found || v != want {
+				t.Errorf("Update(%d): got (%d, %v), want (%d, true)", key, v, found, want)
+			}
+			return -v, Replace
+		})
+		model[model.lower(key)].Value *= -1
+	}
+	for _, key := range []int{1, 4} {
+		tree.Update(key, func(v int, _ bool) (int, Action) { return v, Delete })
+		model.remove(key)
+	}
+	tree.Update(2, func(v int, _ bool) (int, Action) { return v, Keep })
+	tree.Update(10, func(v int, found bool) (int, Action) {
+		if found {
+			t.Errorf("Update(10): found value %d", v)
+		}
+		return 100, Replace
+	})
+	model.insert(10, 100)
+
+	checkTree(t, tree)
+	if diff := cmp.Diff([]KV[int, int](model), contents(tree)); diff != "" {
+		t.Errorf("Contents (-want, +got)\n%s", diff)
+	}
+}
+
+func TestUpdateHelpers(t *testing.T) {
+	tree := intTree(0, false, intRange(0, 10, 2))
+
+	if v, ok := tree.GetOrInsert(4, 100); v != 4 || !ok {
+		t.Errorf("GetOrInsert(4): got (%d, %v), want (4, true)", v, ok)
+	}
+	if v, ok := tree.GetOrInsert(5, 100); v != 100 || ok {
+		t.Errorf("GetOrInsert(5): got (%d, %v), want (100, false)", v, ok)
+	}
+	if old, ok := tree.Swap(6, 60); old != 6 || !ok {
+		t.Errorf("Swap(6): got (%d, %v), want (6, true)", old, ok)
+	}
+	if old, ok := tree.Swap(7, 70); old != 0 || ok {
+		t.Errorf("Swap(7): got (%d, %v), want (0, false)", old, ok)
+	}
+	for _, key := range []int{0, 1, 0} {
+		tree.Upsert(key, func(old int, _ bool) int { return old + 10 })
+	}
+
+	want := []KV[int, int]{
+		{0, 20}, {1, 10}, {2, 2}, {4, 4}, {5, 100}, {6, 60}, {7, 70}, {8, 8},
+	}
+	checkTree(t, tree)
+	if diff := cmp.Diff(want, contents(tree)); diff != "" {
+		t.Errorf("Contents (-want, +got)\n%s", diff)
+	}
+}
+
+func TestUpdateCursor(t *testing.T) {
+	tree := intTree(0, false, intRange(0, 10, 1))
+	c := tree.First()
+	tree.Update(3, func(v int, _ bool) (int, Action) { return v * 2, Replace })
+	tree.Update(20, func(v int, _ bool) (int, Action) { return v, Keep })
+	if !c.Valid() {
+		t.Error("Update of a value invalidated a cursor")
+	}
+	tree.Update(4, func(v int, _ bool) (int, Action) { return v, Delete })
+	if c.Valid() {
+		t.Error("Update that removed a node did not invalidate a cursor")
+	}
+}
+
+func TestUpdateBalance(t *testing.T) {
+	// Adding keys in order by Update must rebalance as Insert does.
+	tree := New[int, int](0)
+	for i := 0; i < 1000; i++ {
+		tree.Upsert(i, func(int, bool) int { return i })
+	}
+	checkTree(t, tree)
+	if h, max := tree.root.height(), tree.limit(tree.Len())+1; h > max {
+		t.Errorf("Tree height is %d, want ≤ %d", h, max)
+	}
+}
@@ -1,11 +1,15 @@
 module github.com/creachadair/scapegoat
 
-go 1.14
+go 1.21
 
 require (
 	bitbucket.org/creachadair/stringset v0.0.8
 	github.com/google/go-cmp v0.4.1
+	golang.org/x/tools v0.1.0
+)
+
+require (
 	golang.org/x/mod v0.4.2 // indirect
 	golang.org/x/sys v0.0.0-20210507161434-a76c4d0a0096 // indirect
-	golang.org/x/tools v0.1.0
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
@@ -0,0 +1,127 @@
+// Package interval implements an interval tree on top of a scapegoat tree.
+//
+// A Tree stores closed intervals with associated values, ordered by their
+// lower bounds. Each node of the underlying tree is augmented with the
+// greatest upper bound of any interval in its subtree, which allows the
+// queries to skip subtrees that cannot contain an overlapping interval. Thus
+// finding the k intervals that overlap a given point or interval takes
+// O((k + 1) lg n) time, for a tree of n intervals.
+package interval
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/creachadair/scapegoat/generic"
+)
+
+// An Interval is the closed interval of values x with Lo ≤ x ≤ Hi.
+type Interval[T cmp.Ordered] struct {
+	Lo, Hi T
+}
+
+// Contains reports whether iv contains the point x.
+func (iv Interval[T]) Contains(x T) bool { return iv.Lo <= x && x <= iv.Hi }
+
+// Overlaps reports whether iv and other have any points in common.
+func (iv Interval[T]) Overlaps(other Interval[T]) bool {
+	return iv.Lo <= other.Hi && other.Lo <= iv.Hi
+}
+
+// compareIntervals orders intervals by their lower bounds, and then by their
+// upper bounds.
+func compareIntervals[T cmp.Ordered](a, b Interval[T]) int {
+	if c := cmp.Compare(a.Lo, b.Lo); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Hi, b.Hi)
+}
+
+// maxHi is the aggregate of a subtree: the greatest upper bound of its
+// intervals, if ok is true. An empty subtree has no upper bound.
+type maxHi[T cmp.Ordered] struct {
+	hi T
+	ok bool
+}
+
+func combineMaxHi[T cmp.Ordered](a, b maxHi[T]) maxHi[T] {
+	if !a.ok || (b.ok && b.hi > a.hi) {
+		return b
+	}
+	return a
+}
+
+// A Tree is an interval tree mapping closed intervals to values of type V.
+// The same interval may be stored more than once, with different values.
+// A *Tree is not safe for concurrent use without external synchronization.
+type Tree[T cmp.Ordered, V any] struct {
+	tree *generic.AggTree[Interval[T], V, maxHi[T]]
+}
+
+// New returns a new empty interval tree with the given balancing factor
+// 0 ≤ β ≤ 1000. See generic.New for a description of the balancing factor.
+//
+// New panics if β < 0 or β > 1000.
+func New[T cmp.Ordered, V any](β int) *Tree[T, V] {
+	t := generic.NewFunc[Interval[T], V](β, compareIntervals[T])
+	t.SetMulti(true)
+	return &Tree[T, V]{tree: generic.Augment(t, generic.Monoid[Interval[T], V, maxHi[T]]{
+		Combine: combineMaxHi[T],
+		Measure: func(kv generic.KV[Interval[T], V]) maxHi[T] {
+			return maxHi[T]{hi: kv.Key.Hi, ok: true}
+		},
+	})}
+}
+
+// Len reports the number of intervals stored in the tree.
+func (t *Tree[T, V]) Len() int { return t.tree.Len() }
+
+// Insert adds iv to the tree with the given value. If iv is already present,
+// the new value is added after the existing ones.
+//
+// Insert panics if iv.Lo > iv.Hi.
+func (t *Tree[T, V]) Insert(iv Interval[T], value V) {
+	if iv.Lo > iv.Hi {
+		panic(fmt.Sprintf("invalid interval [%v, %v]", iv.Lo, iv.Hi))
+	}
+	t.tree.Insert(iv, value)
+}
+
+// Remove removes iv from the tree and reports whether it was present. If iv
+// was inserted more than once, only its first value is removed.
+func (t *Tree[T, V]) Remove(iv Interval[T]) bool { return t.tree.Remove(iv) }
+
+// Lookup calls f with each value associated with iv, in the order they were
+// inserted, until f returns false or no further values are available.
+func (t *Tree[T, V]) Lookup(iv Interval[T], f func(V) bool) { t.tree.LookupAll(iv, f) }
+
+// Inorder calls f for each interval in the tree in order of their lower
+// bounds, until f returns false or no further intervals are available.
+func (t *Tree[T, V]) Inorder(f func(Interval[T], V) bool) {
+	t.tree.Inorder(func(kv generic.KV[Interval[T], V]) bool { return f(kv.Key, kv.Value) })
+}
+
+// Overlapping calls f for each interval in the tree that overlaps the closed
+// interval [lo, hi], in order of their lower bounds, until f returns false or
+// no further intervals are available.
+func (t *Tree[T, V]) Overlapping(lo, hi T, f func(Interval[T], V) bool) {
+	// Skip subtrees whose intervals all end before lo, and stop at the first
+	// interval that starts after hi.
+	t.tree.InorderPrune(func(m maxHi[T]) bool {
+		return m.ok && m.hi >= lo
+	}, func(kv generic.KV[Interval[T], V]) bool {
+		if kv.Key.Lo > hi {
+			return false
+		} else if kv.Key.Hi >= lo {
+			return f(kv.Key, kv.Value)
+		}
+		return true
+	})
+}
+
+// Stabbing calls f for each interval in the tree that contains point, in
+// order of their lower bounds, until f returns false or no further intervals
+// are available.
+func (t *Tree[T, V]) Stabbing(point T, f func(Interval[T], V) bool) {
+	t.Overlapping(point, point, f)
+}
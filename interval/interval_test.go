@@ -0,0 +1,141 @@
+package interval
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type entry struct {
+	iv    Interval[int]
+	value int
+}
+
+// sorted returns a copy of es ordered as the tree orders them.
+func sorted(es []entry) []entry {
+	out := append([]entry(nil), es...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return compareIntervals(out[i].iv, out[j].iv) < 0
+	})
+	return out
+}
+
+func collect(scan func(f func(Interval[int], int) bool)) []entry {
+	var out []entry
+	scan(func(iv Interval[int], v int) bool {
+		out = append(out, entry{iv, v})
+		return true
+	})
+	return out
+}
+
+func TestOverlapping(t *testing.T) {
+	for _, β := range []int{0, 300, 1000} {
+		tree := New[int, int](β)
+		var all []entry
+		rng := rand.New(rand.NewSource(int64(β)))
+		for i := 0; i < 1000; i++ {
+			lo := rng.Intn(1000)
+			iv := Interval[int]{Lo: lo, Hi: lo + rng.Intn(50)}
+			tree.Insert(iv, i)
+			all = append(all, entry{iv, i})
+
+			// Remove some intervals along the way, to exercise rebuilding. The
+			// tree removes the earliest insertion of a duplicated interval.
+			if i%4 == 3 {
+				iv := all[rng.Intn(len(all))].iv
+				if !tree.Remove(iv) {
+					t.Fatalf("Remove(%v) failed", iv)
+				}
+				for n, e := range all {
+					if e.iv == iv {
+						all = append(all[:n], all[n+1:]...)
+						break
+					}
+				}
+			}
+		}
+		if tree.Len() != len(all) {
+			t.Fatalf("β=%d: Len = %d, want %d", β, tree.Len(), len(all))
+		}
+		if diff := cmp.Diff(sorted(all), collect(tree.Inorder), cmp.AllowUnexported(entry{})); diff != "" {
+			t.Fatalf("β=%d: Inorder (-want, +got)\n%s", β, diff)
+		}
+
+		for i := 0; i < 300; i++ {
+			q := Interval[int]{Lo: rng.Intn(1100) - 50}
+			q.Hi = q.Lo + rng.Intn(20)
+			var want []entry
+			for _, e := range sorted(all) {
+				if e.iv.Overlaps(q) {
+					want = append(want, e)
+				}
+			}
+			got := collect(func(f func(Interval[int], int) bool) { tree.Overlapping(q.Lo, q.Hi, f) })
+			if diff := cmp.Diff(want, got, cmp.AllowUnexported(entry{})); diff != "" {
+				t.Errorf("β=%d: Overlapping(%d, %d) (-want, +got)\n%s", β, q.Lo, q.Hi, diff)
+			}
+
+			want = want[:0]
+			for _, e := range sorted(all) {
+				if e.iv.Contains(q.Lo) {
+					want = append(want, e)
+				}
+			}
+			got = collect(func(f func(Interval[int], int) bool) { tree.Stabbing(q.Lo, f) })
+			if diff := cmp.Diff(want, got, cmp.AllowUnexported(entry{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("β=%d: Stabbing(%d) (-want, +got)\n%s", β, q.Lo, diff)
+			}
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	tree := New[string, int](200)
+	iv := Interval[string]{Lo: "b", Hi: "d"}
+	tree.Insert(iv, 1)
+	tree.Insert(Interval[string]{Lo: "a", Hi: "z"}, 2)
+	tree.Insert(iv, 3)
+
+	var vals []int
+	tree.Lookup(iv, func(v int) bool {
+		vals = append(vals, v)
+		return true
+	})
+	if diff := cmp.Diff([]int{1, 3}, vals); diff != "" {
+		t.Errorf("Lookup (-want, +got)\n%s", diff)
+	}
+
+	var stab []int
+	tree.Stabbing("c", func(_ Interval[string], v int) bool {
+		stab = append(stab, v)
+		return true
+	})
+	if diff := cmp.Diff([]int{2, 1, 3}, stab); diff != "" {
+		t.Errorf("Stabbing (-want, +got)\n%s", diff)
+	}
+
+	if !tree.Remove(iv) || tree.Len() != 2 {
+		t.Errorf("Remove(%v): Len = %d, want 2", iv, tree.Len())
+	}
+	vals = vals[:0]
+	tree.Lookup(iv, func(v int) bool {
+		vals = append(vals, v)
+		return true
+	})
+	if diff := cmp.Diff([]int{3}, vals); diff != "" {
+		t.Errorf("Lookup after Remove (-want, +got)\n%s", diff)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	defer func() {
+		if x := recover(); x == nil {
+			t.Error("Insert of an invalid interval did not panic")
+		}
+	}()
+	New[int, bool](0).Insert(Interval[int]{Lo: 2, Hi: 1}, true)
+}
@@ -1,8 +1,13 @@
-// Program mktree copies the scapegoat tree implementation source into a new
-// package with the specified name. This is intended to be invoked from a go
+// Program mktree copies the scapegoat tree package source into a new package
+// with the specified name. This is intended to be invoked from a go
 // generate rule to fill in a package that provides a definition of a Key type
 // and a keyLess function.
 //
+// Trees in the generated package order their keys by keyLess when they are
+// constructed by New. A zero tree allocated by a decoder (for example by
+// encoding/gob) uses the natural order of Key instead, since the generated Tree
+// type is an alias shared by every package with the same Key and Value types.
+//
 // See the bench subdirectory for an example of use.
 package main
 
@@ -1,12 +1,10 @@
 package scapegoat
 
 import (
+	"bytes"
+	"encoding/gob"
 	"flag"
-	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
-	"os"
 	"sort"
 	"strings"
 	"testing"
@@ -18,21 +16,9 @@ import (
 
 var (
 	strictness = flag.Int("balance", 100, "Balancing factor")
-	dotFile    = flag.String("dot", "", "Emit DOT output to this file")
 	sortWords  = flag.Bool("sort", false, "Sort input words before insertion")
 )
 
-func (n *node) height() int {
-	if n == nil {
-		return 0
-	}
-	h := n.left.height()
-	if r := n.right.height(); r > h {
-		h = r
-	}
-	return h + 1
-}
-
 // Construct a tree with the words from input, returning the finished tree and
 // the original words as split by strings.Fields.
 func makeTree(β int, input string) (*Tree, []string) {
@@ -57,50 +43,6 @@ func allWords(tree *Tree) []string {
 	return got
 }
 
-// If an output file is specified, dump a DOT graph of tree.
-func dumpTree(tree *Tree) {
-	if *dotFile == "" {
-		return
-	}
-	f, err := os.Create(*dotFile)
-	if err != nil {
-		log.Fatalf("Unable to create DOT output: %v", err)
-	}
-	dotTree(f, tree.root)
-	if err := f.Close(); err != nil {
-		log.Fatalf("Unable to close output: %v", err)
-	}
-}
-
-// Render tree to a GraphViz graph.
-func dotTree(w io.Writer, root *node) {
-	fmt.Fprintln(w, "digraph Tree {")
-
-	i := 0
-	next := func() int {
-		i++
-		return i
-	}
-
-	var ptree func(*node) int
-	ptree = func(root *node) int {
-		if root == nil {
-			return 0
-		}
-		id := next()
-		fmt.Fprintf(w, "\tN%04d [label=\"%s\"]\n", id, root.key)
-		if lc := ptree(root.left); lc != 0 {
-			fmt.Fprintf(w, "\tN%04d -> N%04d\n", id, lc)
-		}
-		if rc := ptree(root.right); rc != 0 {
-			fmt.Fprintf(w, "\tN%04d -> N%04d\n", id, rc)
-		}
-		return id
-	}
-	ptree(root)
-	fmt.Fprintln(w, "}")
-}
-
 func TestNew(t *testing.T) {
 	tree := New(200,
 		KV{Key: "please"},
@@ -122,8 +64,10 @@ func TestBasicProperties(t *testing.T) {
 		t.Fatalf("Reading text: %v", err)
 	}
 	tree, words := makeTree(*strictness, string(text))
-	t.Logf("Final tree has size %d; height %d", tree.Len(), tree.root.height())
-	dumpTree(tree)
+	t.Logf("Final tree has size %d", tree.Len())
+	if err := tree.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
 
 	got := allWords(tree)
 	want := stringset.New(words...).Elements()
@@ -157,6 +101,10 @@ func TestRemoval(t *testing.T) {
 		}
 	}
 
+	if err := tree.Check(); err != nil {
+		t.Errorf("Check after removal: %v", err)
+	}
+
 	got = allWords(tree)
 	want := stringset.New(words...).Diff(drop).Elements()
 	if diff := cmp.Diff(want, got); diff != "" {
@@ -199,3 +147,27 @@ func TestInorderAfter(t *testing.T) {
 		}
 	}
 }
+
+func TestGobZero(t *testing.T) {
+	tree := New(100, KV{Key: "b", Value: 2}, KV{Key: "a", Value: 1}, KV{Key: "c", Value: 3})
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(tree); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Decoding allocates a zero tree, which uses the natural order of Key.
+	var got *Tree
+	if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, allWords(got)); diff != "" {
+		t.Errorf("Decoded tree (-want, +got)\n%s", diff)
+	}
+	got.Insert("aa", 0)
+	if diff := cmp.Diff([]string{"a", "aa", "b", "c"}, allWords(got)); diff != "" {
+		t.Errorf("Decoded tree after insert (-want, +got)\n%s", diff)
+	}
+	if v, ok := got.Lookup("c"); !ok || v != 3 {
+		t.Errorf("Lookup(c): got (%v, %v), want (3, true)", v, ok)
+	}
+}
@@ -0,0 +1,479 @@
+// Package synctree provides a scapegoat tree that is safe for concurrent use
+// by multiple goroutines.
+//
+// A Tree wraps a *generic.Tree with a reader/writer lock. Lookups and
+// traversals share a read lock, and modifications hold an exclusive write lock.
+// The traversal methods hold the read lock for the whole traversal, so their
+// callbacks must not modify the tree.
+//
+// To iterate over the tree without holding a lock, take a Snapshot. Because
+// trees support copy-on-write cloning, a snapshot costs O(1) and is isolated
+// from later modifications, so it gives a consistent view of the contents at
+// the moment it was taken.
+package synctree
+
+import (
+	"sync"
+
+	"github.com/creachadair/scapegoat/generic"
+)
+
+// A Tree is a scapegoat tree that is safe for concurrent use.
+type Tree[K, V any] struct {
+	mu   sync.RWMutex
+	tree *generic.Tree[K, V]
+}
+
+// New returns a new concurrency-safe tree with the contents of t. The new tree
+// takes ownership of t, which the caller must not use directly afterward.
+func New[K, V any](t *generic.Tree[K, V]) *Tree[K, V] { return &Tree[K, V]{tree: t} }
+
+// Snapshot returns an independent copy of the current contents of s, in
+// constant time. Changes to s after Snapshot returns are not visible in the
+// snapshot, and changes to the snapshot do not affect s. The snapshot is not
+// itself safe for concurrent use.
+func (s *Tree[K, V]) Snapshot() *generic.Tree[K, V] {
+	// Cloning assigns a new ownership epoch to the original tree, so this
+	// requires an exclusive lock.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Clone()
+}
+
+// Clone returns a new concurrency-safe tree with a copy of the contents of s.
+func (s *Tree[K, V]) Clone() *Tree[K, V] { return New(s.Snapshot()) }
+
+// Insert adds key into the tree if it is not already present, and reports
+// whether a new node was added.
+func (s *Tree[K, V]) Insert(key K, value V) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Insert(key, value)
+}
+
+// Replace adds key to the tree, updating an existing key if it is already
+// present. Reports whether a new node was added.
+func (s *Tree[K, V]) Replace(key K, value V) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Replace(key, value)
+}
+
+// Update looks up key in s and calls f with its value, if any, and applies
+// the action returned by f, while holding the lock. The function f must not
+// access s. See generic.Tree.Update.
+func (s *Tree[K, V]) Update(key K, f func(old V, found bool) (V, generic.Action)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.Update(key, f)
+}
+
+// Upsert stores the value returned by f for key in s, adding a new node if key
+// is not present. The function f must not access s.
+// See generic.Tree.Upsert.
+func (s *Tree[K, V]) Upsert(key K, f func(old V, found bool) V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.Upsert(key, f)
+}
+
+// GetOrInsert returns the value for key in s and true, if key is present.
+// Otherwise, it adds key to s with the given value, and returns value and
+// false.
+func (s *Tree[K, V]) GetOrInsert(key K, value V) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.GetOrInsert(key, value)
+}
+
+// Swap stores value for key in s, and returns the previous value for key and
+// whether key was found.
+func (s *Tree[K, V]) Swap(key K, value V) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Swap(key, value)
+}
+
+// Remove key from the tree and report whether it was present.
+func (s *Tree[K, V]) Remove(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Remove(key)
+}
+
+// SetRanked enables or disables order-statistic bookkeeping for s.
+// See generic.Tree.SetRanked.
+func (s *Tree[K, V]) SetRanked(ranked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.SetRanked(ranked)
+}
+
+// SetMulti enables or disables multimap mode for s.
+// See generic.Tree.SetMulti.
+func (s *Tree[K, V]) SetMulti(multi bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.SetMulti(multi)
+}
+
+// InsertAll adds each of the given key/value pairs to s, as if by Insert, and
+// returns the number of new nodes added. See generic.Tree.InsertAll.
+func (s *Tree[K, V]) InsertAll(kvs ...generic.KV[K, V]) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.InsertAll(kvs...)
+}
+
+// RemoveAll removes all the nodes with each of the given keys from s, and
+// returns the number of nodes removed.
+func (s *Tree[K, V]) RemoveAll(keys ...K) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.RemoveAll(keys...)
+}
+
+// RemoveRange removes all the nodes with keys between lo and hi from s, and
+// returns the number of nodes removed. See generic.Tree.RemoveRange.
+func (s *Tree[K, V]) RemoveRange(lo, hi K, b generic.Bounds) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.RemoveRange(lo, hi, b)
+}
+
+// RemoveIf removes from s all the nodes whose key/value pairs satisfy f, and
+// returns the number of nodes removed. The function f must not access s.
+// See generic.Tree.RemoveIf.
+func (s *Tree[K, V]) RemoveIf(f func(generic.KV[K, V]) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.RemoveIf(f)
+}
+
+// Split moves the contents of s into two new trees, left containing all the
+// keys of s less than key, and right containing all the keys greater than or
+// equal to key. Afterward, s is empty.
+func (s *Tree[K, V]) Split(key K) (left, right *Tree[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lo, hi := s.tree.Split(key)
+	return New(lo), New(hi)
+}
+
+// Join moves the contents of a and b into a new tree, and returns the new
+// tree. The keys of a and b must be disjoint ranges. Afterward, both a and b
+// are empty. See generic.Join.
+func Join[K, V any](a, b *Tree[K, V]) *Tree[K, V] {
+	// Take the contents of each tree in turn, to avoid holding both locks.
+	ta, tb := a.take(), b.take()
+	return New(generic.Join(ta, tb))
+}
+
+// take removes and returns the contents of s, leaving s empty.
+func (s *Tree[K, V]) take() *generic.Tree[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	min := s.tree.Min()
+	if min == nil {
+		return s.tree.Clone()
+	}
+	// Splitting at the minimum moves everything to the right, and leaves the
+	// original tree empty.
+	_, all := s.tree.Split(min.Key)
+	return all
+}
+
+// Len reports the number of elements stored in the tree.
+func (s *Tree[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Len()
+}
+
+// OnRebuild registers f to be called after each rebuild of the tree. The hook
+// is called while s is locked, and must not access s.
+// See generic.Tree.OnRebuild.
+func (s *Tree[K, V]) OnRebuild(f func(generic.RebuildEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.OnRebuild(f)
+}
+
+// Rebalance rebuilds the tree into a perfectly balanced tree.
+// See generic.Tree.Rebalance.
+func (s *Tree[K, V]) Rebalance() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.Rebalance()
+}
+
+// SetBalance sets the balancing factor of the tree, and rebalances it if
+// rebalance is true. See generic.Tree.SetBalance.
+func (s *Tree[K, V]) SetBalance(β int, rebalance bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.SetBalance(β, rebalance)
+}
+
+// SetRebuildQuantum enables deamortized rebuilding for the tree with work
+// quantum q > 0, or disables it if q ≤ 0.
+// See generic.Tree.SetRebuildQuantum.
+func (s *Tree[K, V]) SetRebuildQuantum(q int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.SetRebuildQuantum(q)
+}
+
+// FinishRebuild completes any deamortized rebuild of the tree in progress.
+// See generic.Tree.FinishRebuild.
+func (s *Tree[K, V]) FinishRebuild() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.FinishRebuild()
+}
+
+// Stats returns statistics about the shape of the tree and its rebuilds.
+// See generic.Tree.Stats.
+func (s *Tree[K, V]) Stats() generic.Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Stats()
+}
+
+// Check verifies the structural invariants of the tree, and reports the first
+// violation found. See generic.Tree.Check.
+func (s *Tree[K, V]) Check() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Check()
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+// See generic.Tree.MarshalBinary.
+func (s *Tree[K, V]) MarshalBinary() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.MarshalBinary()
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. A
+// zero-valued Tree, as allocated by a decoder, is ready for use afterward.
+// See generic.Tree.UnmarshalBinary.
+func (s *Tree[K, V]) UnmarshalBinary(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.target().UnmarshalBinary(data)
+}
+
+// GobEncode implements the gob.GobEncoder interface, using the same encoding
+// as MarshalBinary.
+func (s *Tree[K, V]) GobEncode() ([]byte, error) { return s.MarshalBinary() }
+
+// GobDecode implements the gob.GobDecoder interface, using the same encoding
+// as UnmarshalBinary.
+func (s *Tree[K, V]) GobDecode(data []byte) error { return s.UnmarshalBinary(data) }
+
+// MarshalJSON implements the json.Marshaler interface.
+// See generic.Tree.MarshalJSON.
+func (s *Tree[K, V]) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.MarshalJSON()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. As with
+// UnmarshalBinary, a zero-valued Tree is ready for use afterward.
+// See generic.Tree.UnmarshalJSON.
+func (s *Tree[K, V]) UnmarshalJSON(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.target().UnmarshalJSON(data)
+}
+
+// target returns the tree wrapped by s, allocating an empty one if s is a zero
+// value. The caller must hold the write lock.
+func (s *Tree[K, V]) target() *generic.Tree[K, V] {
+	if s.tree == nil {
+		s.tree = new(generic.Tree[K, V])
+	}
+	return s.tree
+}
+
+// Lookup reports whether key is present in the tree, and returns the value
+// associated with that key, or a zero value if the key is not present.
+func (s *Tree[K, V]) Lookup(key K) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Lookup(key)
+}
+
+// LookupAll calls f with each value associated with key in s, in order, until
+// f returns false or no further values are available. The read lock is held
+// for the duration of the traversal.
+func (s *Tree[K, V]) LookupAll(key K, f func(V) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.tree.LookupAll(key, f)
+}
+
+// Min returns the key/value pair in the tree with the minimum key, or nil if
+// the tree is empty.
+func (s *Tree[K, V]) Min() *generic.KV[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Min()
+}
+
+// Max returns the key/value pair in the tree with the maximum key, or nil if
+// the tree is empty.
+func (s *Tree[K, V]) Max() *generic.KV[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Max()
+}
+
+// PopMin removes the key/value pair with the minimum key from the tree and
+// returns it, reporting whether the tree was non-empty.
+func (s *Tree[K, V]) PopMin() (generic.KV[K, V], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.PopMin()
+}
+
+// PopMax removes the key/value pair with the maximum key from the tree and
+// returns it, reporting whether the tree was non-empty.
+func (s *Tree[K, V]) PopMax() (generic.KV[K, V], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.PopMax()
+}
+
+// PopMinN removes up to n key/value pairs with the smallest keys from the tree
+// and returns them in order. See generic.Tree.PopMinN.
+func (s *Tree[K, V]) PopMinN(n int) []generic.KV[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.PopMinN(n)
+}
+
+// Floor returns the key/value pair with the greatest key less than or equal to
+// key, and reports whether such a key exists.
+func (s *Tree[K, V]) Floor(key K) (generic.KV[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Floor(key)
+}
+
+// Predecessor returns the key/value pair with the greatest key strictly less
+// than key, and reports whether such a key exists.
+func (s *Tree[K, V]) Predecessor(key K) (generic.KV[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Predecessor(key)
+}
+
+// Ceiling returns the key/value pair with the least key greater than or equal
+// to key, and reports whether such a key exists.
+func (s *Tree[K, V]) Ceiling(key K) (generic.KV[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Ceiling(key)
+}
+
+// Successor returns the key/value pair with the least key strictly greater
+// than key, and reports whether such a key exists.
+func (s *Tree[K, V]) Successor(key K) (generic.KV[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Successor(key)
+}
+
+// Rank reports the number of keys in the tree strictly less than key.
+func (s *Tree[K, V]) Rank(key K) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Rank(key)
+}
+
+// At returns the key/value pair at index i in the inorder sequence of the
+// tree, and reports whether i is in range.
+func (s *Tree[K, V]) At(i int) (generic.KV[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.At(i)
+}
+
+// Select returns the key/value pair at index i in the inorder sequence of the
+// tree. Select panics if i is out of range.
+func (s *Tree[K, V]) Select(i int) generic.KV[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Select(i)
+}
+
+// Inorder traverses the tree inorder and invokes f for each key until either f
+// returns false or no further keys are available. The read lock is held for
+// the duration of the traversal.
+func (s *Tree[K, V]) Inorder(f func(generic.KV[K, V]) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.tree.Inorder(f)
+}
+
+// InorderAfter traverses the tree inorder, considering only keys equal to or
+// after key. The read lock is held for the duration of the traversal.
+func (s *Tree[K, V]) InorderAfter(key K, f func(generic.KV[K, V]) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.tree.InorderAfter(key, f)
+}
+
+// InorderBefore traverses the tree in reverse order, considering only keys
+// equal to or before key. The read lock is held for the duration of the
+// traversal.
+func (s *Tree[K, V]) InorderBefore(key K, f func(generic.KV[K, V]) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.tree.InorderBefore(key, f)
+}
+
+// ReverseInorder traverses the tree in reverse order. The read lock is held
+// for the duration of the traversal.
+func (s *Tree[K, V]) ReverseInorder(f func(generic.KV[K, V]) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.tree.ReverseInorder(f)
+}
+
+// InorderRange traverses the tree inorder, considering only keys between lo
+// and hi according to b. The read lock is held for the duration of the
+// traversal.
+func (s *Tree[K, V]) InorderRange(lo, hi K, b generic.Bounds, f func(generic.KV[K, V]) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.tree.InorderRange(lo, hi, b, f)
+}
+
+// Union returns a new tree containing the keys present in either s or u.
+// See generic.Tree.Union.
+func (s *Tree[K, V]) Union(u *Tree[K, V], resolve func(key K, a, b V) V) *Tree[K, V] {
+	return New(s.Snapshot().Union(u.Snapshot(), resolve))
+}
+
+// Intersect returns a new tree containing the keys present in both s and u,
+// with their values from s.
+func (s *Tree[K, V]) Intersect(u *Tree[K, V]) *Tree[K, V] {
+	return New(s.Snapshot().Intersect(u.Snapshot()))
+}
+
+// Difference returns a new tree containing the keys of s that are not present
+// in u.
+func (s *Tree[K, V]) Difference(u *Tree[K, V]) *Tree[K, V] {
+	return New(s.Snapshot().Difference(u.Snapshot()))
+}
+
+// SymmetricDifference returns a new tree containing the keys present in
+// exactly one of s and u.
+func (s *Tree[K, V]) SymmetricDifference(u *Tree[K, V]) *Tree[K, V] {
+	return New(s.Snapshot().SymmetricDifference(u.Snapshot()))
+}
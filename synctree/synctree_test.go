@@ -0,0 +1,255 @@
+package synctree_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/creachadair/scapegoat/generic"
+	"github.com/creachadair/scapegoat/synctree"
+)
+
+// Run these tests with -race to check for unsynchronized access.
+
+func TestConcurrentAccess(t *testing.T) {
+	const numWriters = 4
+	const numKeys = 300
+
+	tree := synctree.New(generic.New[int, int](100))
+	tree.SetRanked(true)
+
+	var writers, readers sync.WaitGroup
+	done := make(chan struct{})
+
+	// Each writer inserts its own keys, removing every third one again.
+	for w := 0; w < numWriters; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < numKeys; i++ {
+				key := i*numWriters + w
+				tree.Insert(key, -key)
+				if i%3 == 0 {
+					tree.Remove(key)
+				} else if i%3 == 1 {
+					tree.Replace(key, key)
+				}
+			}
+		}(w)
+	}
+
+	// Readers check that every view of the tree is internally consistent.
+	check := func(name string, snap *generic.Tree[int, int]) {
+		n, prev := 0, -1
+		snap.Inorder(func(kv generic.KV[int, int]) bool {
+			if kv.Key <= prev {
+				t.Errorf("%s: key %d out of order after %d", name, kv.Key, prev)
+			}
+			prev = kv.Key
+			n++
+			return true
+		})
+		if n != snap.Len() {
+			t.Errorf("%s: traversed %d keys, but Len is %d", name, n, snap.Len())
+		}
+		if n != 0 && snap.Rank(prev) != n-1 {
+			t.Errorf("%s: Rank(%d) = %d, want %d", name, prev, snap.Rank(prev), n-1)
+		}
+	}
+	reader := func(f func()) {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					f()
+				}
+			}
+		}()
+	}
+	reader(func() { check("snapshot", tree.Snapshot()) })
+	reader(func() {
+		prev := -1
+		tree.Inorder(func(kv generic.KV[int, int]) bool {
+			if kv.Key <= prev {
+				t.Errorf("Inorder: key %d out of order after %d", kv.Key, prev)
+			}
+			prev = kv.Key
+			return true
+		})
+	})
+	reader(func() {
+		if min := tree.Min(); min != nil {
+			if v, ok := tree.Lookup(min.Key); ok && v != min.Key && v != -min.Key {
+				t.Errorf("Lookup(%d): got %d", min.Key, v)
+			}
+		}
+		tree.Ceiling(numKeys)
+		tree.At(tree.Len() / 2)
+	})
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	final := tree.Snapshot()
+	check("final", final)
+	want := numWriters * (numKeys - (numKeys+2)/3)
+	if got := tree.Len(); got != want {
+		t.Errorf("Final Len: got %d, want %d", got, want)
+	}
+	tree.Inorder(func(kv generic.KV[int, int]) bool {
+		want := kv.Key // replaced
+		switch kv.Key / numWriters % 3 {
+		case 0:
+			t.Errorf("Key %d should have been removed", kv.Key)
+		case 2:
+			want = -kv.Key // inserted only
+		}
+		if kv.Value != want {
+			t.Errorf("Key %d: got value %d, want %d", kv.Key, kv.Value, want)
+		}
+		return true
+	})
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	tree := synctree.New(generic.New[string, int](200))
+	for i, w := range []string{"red", "orange", "yellow", "green", "blue"} {
+		tree.Insert(w, i)
+	}
+	snap := tree.Snapshot()
+	tree.Remove("red")
+	tree.Replace("blue", 100)
+	snap.Insert("violet", 6)
+
+	if _, ok := snap.Lookup("red"); !ok {
+		t.Error("Snapshot lost a key removed from the tree")
+	}
+	if v, _ := snap.Lookup("blue"); v != 4 {
+		t.Errorf("Snapshot blue = %d, want 4", v)
+	}
+	if _, ok := tree.Lookup("violet"); ok {
+		t.Error("Tree saw a key inserted into the snapshot")
+	}
+	if got, want := tree.Len(), 4; got != want {
+		t.Errorf("Tree Len: got %d, want %d", got, want)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	tree := synctree.New(generic.New[string, int](100))
+	for i, w := range []string{"alpha", "bravo", "charlie", "delta"} {
+		tree.Insert(w, i)
+	}
+
+	// Gob allocates a zero-valued tree to decode into.
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(tree); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var got *synctree.Tree[string, int]
+	if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if err := got.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	if got.Len() != tree.Len() {
+		t.Errorf("Len: got %d, want %d", got.Len(), tree.Len())
+	}
+	if v, ok := got.Lookup("charlie"); !ok || v != 2 {
+		t.Errorf("Lookup(charlie): got %d, %v; want 2, true", v, ok)
+	}
+	got.Insert("echo", 4) // the decoded tree is usable
+	if _, ok := tree.Lookup("echo"); ok {
+		t.Error("Decoded tree shares state with the original")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tree := synctree.New(generic.New[string, int](100))
+	tree.Insert("b", 2)
+	tree.Insert("a", 1)
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got, want := string(data), `{"a":1,"b":2}`; got != want {
+		t.Errorf("Marshal: got %s, want %s", got, want)
+	}
+	var got struct{ T *synctree.Tree[string, int] }
+	if err := json.Unmarshal([]byte(`{"T":`+string(data)+`}`), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if err := got.T.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	if v, ok := got.T.Lookup("b"); !ok || v != 2 {
+		t.Errorf("Lookup(b): got %d, %v; want 2, true", v, ok)
+	}
+}
+
+func TestSplitJoin(t *testing.T) {
+	tree := synctree.New(generic.New[int, string](0))
+	for i := 0; i < 100; i++ {
+		tree.Insert(i, "")
+	}
+	lo, hi := tree.Split(40)
+	if tree.Len() != 0 || lo.Len() != 40 || hi.Len() != 60 {
+		t.Errorf("Split sizes: got %d, %d, %d; want 0, 40, 60", tree.Len(), lo.Len(), hi.Len())
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); lo.Insert(-1, "") }()
+	go func() { defer wg.Done(); hi.Insert(200, "") }()
+	wg.Wait()
+
+	all := synctree.Join(hi, lo)
+	if lo.Len() != 0 || hi.Len() != 0 || all.Len() != 102 {
+		t.Errorf("Join sizes: got %d, %d, %d; want 0, 0, 102", lo.Len(), hi.Len(), all.Len())
+	}
+	if min, max := all.Min(), all.Max(); min.Key != -1 || max.Key != 200 {
+		t.Errorf("Joined range: got [%d, %d], want [-1, 200]", min.Key, max.Key)
+	}
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	const numWriters = 8
+	const numKeys = 20
+	const numIncrements = 500
+
+	// Concurrent read-modify-write updates of shared counters must not lose
+	// any increments.
+	tree := synctree.New(generic.New[int, int](100))
+	var wg sync.WaitGroup
+	for w := 0; w < numWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < numIncrements; i++ {
+				tree.Upsert((i+w)%numKeys, func(old int, _ bool) int { return old + 1 })
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total := 0
+	tree.Inorder(func(kv generic.KV[int, int]) bool {
+		total += kv.Value
+		return true
+	})
+	if tree.Len() != numKeys {
+		t.Errorf("Len = %d, want %d", tree.Len(), numKeys)
+	}
+	if want := numWriters * numIncrements; total != want {
+		t.Errorf("Sum of counters = %d, want %d", total, want)
+	}
+}